@@ -3,6 +3,6 @@ package vbasedata
 type App struct {
 	AppName string `yaml:"app_name" json:"app_name"`
 	Env     string `yaml:"env" json:"env"`
-	Health  string `yaml:"health" json:"health"`
-	Trace   string `yaml:"trace" json:"trace"`
+	Health  string `yaml:"health" json:"health"` // 健康检查路径，如/health，挂载到http服务：/health/live存活，/health和/health/ready就绪
+	Trace   string `yaml:"trace" json:"trace"`   // 链路追踪 none/stdout/memory/otlp-http://host:4318[/path]/otlp-https://...，默认none
 }
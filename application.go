@@ -0,0 +1,156 @@
+package vbasedata
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/go-kratos/kratos/v2"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/go-kratos/kratos/v2/transport/grpc"
+	"github.com/go-kratos/kratos/v2/transport/http"
+	redis "github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Application 按Bootstrap组装的应用，未配置的组件为nil
+type Application struct {
+	App   *kratos.App
+	Http  *http.Server
+	Grpc  *grpc.Server
+	DB    *gorm.DB
+	Gorms *GormManager
+	Redis redis.UniversalClient
+	Pond  *Pond
+	IdGen *Idgenerator
+	Email *Email
+
+	// Health 各组件自动注册的健康检查
+	Health *HealthRegistry
+
+	cleanups []func()
+	once     sync.Once
+}
+
+// NewApplication 按配置初始化所有组件并创建kratos应用，opts追加到kratos选项之后
+func NewApplication(b *Bootstrap, logger *log.Helper, opts ...kratos.Option) (*Application, func(), error) {
+	return NewApplicationContext(context.Background(), b, logger, opts...)
+}
+
+// NewApplicationContext 同NewApplication，ctx传递给数据库和redis的初始化。
+// 应用停止后按初始化的相反顺序关闭各组件，未运行时调用返回的清理函数关闭，重复调用只执行一次
+func NewApplicationContext(ctx context.Context, b *Bootstrap, logger *log.Helper, opts ...kratos.Option) (*Application, func(), error) {
+	if b == nil {
+		return nil, nil, errors.New("Bootstrap配置参数不能为空")
+	}
+	b.ApplyDefaults()
+	if err := b.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	a := &Application{Health: DefaultHealth}
+	// 最先初始化链路追踪，使后续组件使用全局TracerProvider，关闭时最后导出
+	if b.App != nil {
+		_, f, err := NewTracerProviderContext(ctx, b.App, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		a.cleanups = append(a.cleanups, f)
+	}
+	if b.Gorm != nil {
+		db, f, err := NewGormContext(ctx, b.Gorm, logger)
+		if err != nil {
+			a.Close()
+			return nil, nil, err
+		}
+		a.DB = db
+		a.cleanups = append(a.cleanups, f)
+	}
+	if len(b.Gorms) > 0 {
+		m, f, err := NewGormManagerContext(ctx, b.Gorms, logger)
+		if err != nil {
+			a.Close()
+			return nil, nil, err
+		}
+		a.Gorms = m
+		a.cleanups = append(a.cleanups, f)
+	}
+	if b.Redis != nil {
+		rdb, f, err := NewRedisContext(ctx, b.Redis, logger)
+		if err != nil {
+			a.Close()
+			return nil, nil, err
+		}
+		a.Redis = rdb
+		a.cleanups = append(a.cleanups, f)
+	}
+	if b.Pond != nil {
+		a.Pond = NewPond(b.Pond, logger)
+		a.cleanups = append(a.cleanups, func() {
+			logger.Info("Pond 停止")
+			a.Pond.Stop()
+		})
+	}
+	if b.Idgen != nil {
+		a.IdGen = NewIdgenerator(b.Idgen.WorkerId)
+	}
+	if b.Email != nil {
+		a.Email = NewEmail(b.Email)
+		a.cleanups = append(a.cleanups, a.Email.Close)
+	}
+
+	var servers []transport.Server
+	if b.Http != nil {
+		httpOpts, err := NewHttpServerOptions(b.Http, logger)
+		if err != nil {
+			a.Close()
+			return nil, nil, err
+		}
+		a.Http = http.NewServer(httpOpts...)
+		if b.App != nil && b.App.Health != "" {
+			RegisterHealthHandler(a.Http, b.App.Health, a.Health)
+		}
+		servers = append(servers, a.Http)
+	}
+	if b.Grpc != nil {
+		grpcOpts, err := NewGrpcServerOptions(b.Grpc, logger)
+		if err != nil {
+			a.Close()
+			return nil, nil, err
+		}
+		a.Grpc = grpc.NewServer(grpcOpts...)
+		servers = append(servers, a.Grpc)
+	}
+
+	kopts := []kratos.Option{
+		kratos.Logger(logger.Logger()),
+		kratos.Server(servers...),
+		kratos.AfterStop(func(context.Context) error {
+			a.Close()
+			return nil
+		}),
+	}
+	if b.App != nil {
+		kopts = append(kopts,
+			kratos.Name(b.App.AppName),
+			kratos.Metadata(map[string]string{"env": b.App.Env}),
+		)
+	}
+	a.App = kratos.New(append(kopts, opts...)...)
+	return a, a.Close, nil
+}
+
+// Run 启动应用并阻塞到收到退出信号，退出后关闭所有组件
+func (a *Application) Run() error {
+	return a.App.Run()
+}
+
+// Close 按初始化的相反顺序关闭所有组件，重复调用只执行一次
+func (a *Application) Close() {
+	a.once.Do(func() {
+		for i := len(a.cleanups) - 1; i >= 0; i-- {
+			a.cleanups[i]()
+		}
+	})
+}
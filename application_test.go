@@ -0,0 +1,76 @@
+package vbasedata
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2"
+	"gorm.io/gorm"
+)
+
+func TestNewApplication(t *testing.T) {
+	if _, _, err := NewApplication(&Bootstrap{Gorm: &GormConfig{Type: "oracle"}}, newTestLogger()); err == nil {
+		t.Fatal("invalid bootstrap should fail")
+	}
+	if _, _, err := NewApplication(&Bootstrap{Gorms: []*GormConfig{{Name: "a"}, {Name: "a"}}}, newTestLogger()); err == nil {
+		t.Fatal("duplicate gorms name should fail")
+	}
+
+	started := make(chan struct{})
+	b := &Bootstrap{
+		App:  &App{AppName: "demo", Env: "test"},
+		Http: &Http{Addr: "127.0.0.1:0", Timeout: "1s"},
+		Gorm: &GormConfig{DBPath: filepath.Join(t.TempDir(), "app.db")},
+		Gorms: []*GormConfig{
+			{Name: "order", DBPath: filepath.Join(t.TempDir(), "order.db")},
+			{Name: "user", DBPath: filepath.Join(t.TempDir(), "user.db")},
+		},
+		Pond:  &PondConfig{},
+		Idgen: &IdgenConfig{WorkerId: 1},
+	}
+	a, cleanup, err := NewApplication(b, newTestLogger(), kratos.AfterStart(func(context.Context) error {
+		close(started)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	if a.DB == nil || a.Gorms == nil || a.Pond == nil || a.IdGen == nil || a.Http == nil || a.Redis != nil || a.Grpc != nil {
+		t.Fatalf("unexpected components: %+v", a)
+	}
+	if names := a.Gorms.Names(); len(names) != 2 || names[0] != "order" || names[1] != "user" {
+		t.Fatalf("gorms = %v", names)
+	}
+	if a.App.Name() != "demo" || a.App.Metadata()["env"] != "test" {
+		t.Fatalf("app = %v %v", a.App.Name(), a.App.Metadata())
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- a.Run()
+	}()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("app did not start")
+	}
+	if err := a.App.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, db := range []*gorm.DB{a.DB, a.Gorms.Get("order"), a.Gorms.Get("user")} {
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sqlDB.Ping(); err == nil {
+			t.Fatal("db should be closed after stop")
+		}
+	}
+}
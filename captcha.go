@@ -2,6 +2,8 @@ package vbasedata
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"image/color"
 	"time"
 
@@ -9,68 +11,255 @@ import (
 )
 
 type CaptchaConfig struct {
-	Width      int           `json:"width" yaml:"width"`
-	Height     int           `json:"height" yaml:"height"`
-	Fonts      []string      `json:"fonts" yaml:"fonts"`
-	BgColor    *color.RGBA   `json:"bg_color" yaml:"bg_color"`
-	StorageLen int           `json:"storage_len" yaml:"storage_len"`
-	StroageExp time.Duration `json:"stroage_exp" yaml:"stroage_exp"`
+	Type       string                `json:"type" yaml:"type"` // 类型 digit/string/chinese/math/audio/slider/click，默认math
+	Width      int                   `json:"width" yaml:"width"`
+	Height     int                   `json:"height" yaml:"height"`
+	Fonts      []string              `json:"fonts" yaml:"fonts"`
+	FontDir    string                `json:"font_dir" yaml:"font_dir"` // 字体目录，设置后fonts从该目录加载，chinese类型需要提供中文字体
+	BgColor    *color.RGBA           `json:"bg_color" yaml:"bg_color"`
+	StorageLen int                   `json:"storage_len" yaml:"storage_len"`
+	StroageExp time.Duration         `json:"stroage_exp" yaml:"stroage_exp"`
+	KeyPrefix  string                `json:"key_prefix" yaml:"key_prefix"` // RedisStore的key前缀
+	Digit      *CaptchaDigitConfig   `json:"digit" yaml:"digit"`           // digit类型配置
+	String     *CaptchaStringConfig  `json:"string" yaml:"string"`         // string类型配置
+	Chinese    *CaptchaChineseConfig `json:"chinese" yaml:"chinese"`       // chinese类型配置
+	Math       *CaptchaMathConfig    `json:"math" yaml:"math"`             // math类型配置
+	Audio      *CaptchaAudioConfig   `json:"audio" yaml:"audio"`           // audio类型配置
+	Limit      *CaptchaLimitConfig   `json:"limit" yaml:"limit"`           // 失败次数限制
+	Verify     *CaptchaVerifyConfig  `json:"verify" yaml:"verify"`         // 答案比较方式，默认精确匹配
+	Slider     *CaptchaSliderConfig  `json:"slider" yaml:"slider"`         // slider类型配置
+	Click      *CaptchaClickConfig   `json:"click" yaml:"click"`           // click类型配置
+	Track      *CaptchaTrackConfig   `json:"track" yaml:"track"`           // slider/click拖动轨迹校验
 }
 
 type Captcha struct {
 	stor    base64Captcha.Store
 	captcha *base64Captcha.Captcha
+	limit   CaptchaLimitConfig
+	verify  CaptchaVerifyConfig
+	prefix  string
+	exp     time.Duration
+	counter AttemptCounter // 按验证码id计数
+	clients AttemptCounter // 按客户端计数和锁定
+
+	// slider/click
+	typ    string
+	width  int
+	height int
+	slider CaptchaSliderConfig
+	click  CaptchaClickConfig
+	track  CaptchaTrackConfig
 }
 
+// NewCaptcha 同NewCaptchaE，字体加载失败时panic
 func NewCaptcha(c *CaptchaConfig, stor base64Captcha.Store) *Captcha {
-	if c.Width == 0 {
-		c.Width = 320
+	r, err := NewCaptchaE(c, stor)
+	if err != nil {
+		panic(fmt.Sprintf("验证码初始化失败: %v", err))
 	}
+	return r
+}
 
-	if c.Height == 0 {
-		c.Height = 120
+// NewCaptchaE 创建验证码，字体在创建时加载，缺失或无法解析时返回错误
+func NewCaptchaE(c *CaptchaConfig, stor base64Captcha.Store) (*Captcha, error) {
+	if c == nil {
+		return nil, errors.New("验证码配置参数不能为空")
+	}
+	setCaptchaDefaults(c)
+	var errs configErrors
+	validateCaptchaInteractive(&errs, "captcha", c)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	driver, err := newCaptchaDriver(c)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(c.Fonts) == 0 {
-		c.Fonts = append(c.Fonts, "actionj.ttf")
+	// 实例化
+	return &Captcha{
+		stor:    stor,
+		captcha: base64Captcha.NewCaptcha(driver, stor),
+		limit:   *c.Limit,
+		verify:  *c.Verify,
+		prefix:  c.KeyPrefix,
+		exp:     c.StroageExp,
+		counter: NewMemoryAttemptCounter(c.StorageLen),
+		clients: NewExpiringAttemptCounter(),
+		typ:     c.Type,
+		width:   c.Width,
+		height:  c.Height,
+		slider:  *c.Slider,
+		click:   *c.Click,
+		track:   *c.Track,
+	}, nil
+}
+
+// SetAttemptCounter 设置失败计数器，验证码id和客户端都使用该计数器。
+// 默认为进程内计数，id计数随StorageLen淘汰，客户端计数和锁定不淘汰；多实例部署时应使用NewRedisAttemptCounter
+func (r *Captcha) SetAttemptCounter(counter AttemptCounter) {
+	r.counter = counter
+	r.clients = counter
+}
+
+// ContextStore 支持ctx的验证码存储，Captcha会优先使用这些方法，使ctx的超时和取消传递到存储层
+type ContextStore interface {
+	base64Captcha.Store
+	SetContext(ctx context.Context, id string, value string) error
+	VerifyContext(ctx context.Context, id, answer string, clear bool) bool
+}
+
+// GetCaptCha 生成文字/语音验证码，slider/click类型使用GetInteractive
+func (r *Captcha) GetCaptCha(ctx context.Context) (id, b64s, answer string, err error) {
+	if err := ctx.Err(); err != nil {
+		return "", "", "", err
+	}
+	if isInteractiveType(r.typ) {
+		return "", "", "", fmt.Errorf("%v类型验证码请使用GetInteractive", r.typ)
+	}
+	id, content, answer := r.captcha.Driver.GenerateIdQuestionAnswer()
+	item, err := r.captcha.Driver.DrawCaptcha(content)
+	if err != nil {
+		return "", "", "", err
+	}
+	if err := r.set(ctx, id, answer); err != nil {
+		return "", "", "", err
 	}
+	return id, item.EncodeB64string(), answer, nil
+}
 
-	if c.BgColor == nil {
-		c.BgColor = &color.RGBA{
-			R: 255,
-			B: 255,
-			G: 255,
-		}
+// Verify 校验验证码，只按验证码id限制失败次数，需要按客户端锁定时使用VerifyResult
+func (r *Captcha) Verify(ctx context.Context, id, VerifyValue string) (b bool) {
+	res, err := r.VerifyResult(ctx, id, VerifyValue, "")
+	return err == nil && res == VerifyOK
+}
+
+// VerifyResult 校验验证码，clientKey为客户端标识(IP/用户ID)，为空时不按客户端计数。
+// 答案按Verify配置规范化后比较，答错时验证码作废(KeepOnFailure时保留，失败MaxFailures次后作废)；同一客户端在Window内失败ClientMaxFailures次后锁定Lockout，通过后清除该客户端的失败计数。
+// 返回错误时结果为VerifyWrong
+func (r *Captcha) VerifyResult(ctx context.Context, id, answer, clientKey string) (VerifyResult, error) {
+	return r.verifyWith(ctx, id, clientKey, func(v string) bool {
+		// 交互式验证码的答案只能通过VerifyPosition校验，避免绕过误差和轨迹检查
+		return !isInteractiveAnswer(v) && r.verify.match(v, answer)
+	})
+}
+
+// verifyWith 按失败次数限制校验，match判断存储的答案是否通过。
+// 比较前先占用一次次数，并发猜测同一个验证码时最多MaxFailures个请求能进入比较
+func (r *Captcha) verifyWith(ctx context.Context, id, clientKey string, match func(v string) bool) (VerifyResult, error) {
+	if err := ctx.Err(); err != nil {
+		return VerifyWrong, err
 	}
+	idKey := r.prefix + "attempt:id:" + id
+	clientAttemptKey := r.prefix + "attempt:client:" + clientKey
+	lockKey := r.prefix + "lock:client:" + clientKey
 
-	if c.StorageLen == 0 {
-		c.StorageLen = 5000
+	if clientKey != "" {
+		n, err := r.clients.Get(ctx, lockKey)
+		if err != nil {
+			return VerifyWrong, err
+		}
+		if n > 0 {
+			return VerifyLocked, nil
+		}
+	}
+	n, err := r.counter.Get(ctx, idKey)
+	if err != nil {
+		return VerifyWrong, err
+	}
+	if n >= int64(r.limit.MaxFailures) {
+		return VerifyLocked, nil
+	}
+	v, err := r.get(ctx, id, false)
+	if err != nil {
+		return VerifyWrong, err
+	}
+	if v == "" {
+		// 不存在的id不计数，避免大量无效id把有效id的计数挤出缓存
+		return VerifyExpired, nil
+	}
+	n, err = r.counter.Incr(ctx, idKey, r.exp)
+	if err != nil {
+		return VerifyWrong, err
+	}
+	if n > int64(r.limit.MaxFailures) {
+		if _, err := r.get(ctx, id, true); err != nil {
+			return VerifyWrong, err
+		}
+		return VerifyLocked, nil
+	}
+	var cn int64
+	if clientKey != "" {
+		cn, err = r.clients.Incr(ctx, clientAttemptKey, time.Duration(r.limit.Window)*time.Second)
+		if err != nil {
+			return VerifyWrong, err
+		}
+		if cn > int64(r.limit.ClientMaxFailures) {
+			if err := r.lockClient(ctx, clientAttemptKey, lockKey); err != nil {
+				return VerifyWrong, err
+			}
+			return VerifyLocked, nil
+		}
 	}
 
-	if c.StroageExp == 0 {
-		c.StroageExp = 6 * time.Minute
+	if match(v) {
+		// 取走答案，同一个答案只能通过一次；要求存储的Get(clear)原子地读取并删除，LruCache和RedisStore满足
+		taken, err := r.get(ctx, id, true)
+		if err != nil {
+			return VerifyWrong, err
+		}
+		if taken == "" {
+			return VerifyExpired, nil
+		}
+		// 通过后清除占用的次数，客户端只累计失败
+		_ = r.counter.Reset(ctx, idKey)
+		if clientKey != "" {
+			_ = r.clients.Reset(ctx, clientAttemptKey)
+		}
+		return VerifyOK, nil
 	}
 
-	dv := &base64Captcha.DriverMath{
-		Width:   c.Width,
-		Height:  c.Height,
-		Fonts:   c.Fonts,
-		BgColor: c.BgColor,
+	res := VerifyWrong
+	if n >= int64(r.limit.MaxFailures) {
+		res = VerifyLocked
 	}
-	dv = dv.ConvertFonts()
+	if res == VerifyLocked || !r.verify.KeepOnFailure {
+		if _, err := r.get(ctx, id, true); err != nil {
+			return VerifyWrong, err
+		}
+	}
+	if clientKey != "" && cn >= int64(r.limit.ClientMaxFailures) {
+		if err := r.lockClient(ctx, clientAttemptKey, lockKey); err != nil {
+			return VerifyWrong, err
+		}
+		res = VerifyLocked
+	}
+	return res, nil
+}
 
-	// 实例化
-	return &Captcha{
-		stor:    stor,
-		captcha: base64Captcha.NewCaptcha(dv, stor),
+// lockClient 锁定客户端Lockout，并清除失败计数
+func (r *Captcha) lockClient(ctx context.Context, clientAttemptKey, lockKey string) error {
+	if _, err := r.clients.Incr(ctx, lockKey, time.Duration(r.limit.Lockout)*time.Second); err != nil {
+		return err
 	}
+	return r.clients.Reset(ctx, clientAttemptKey)
 }
 
-func (r *Captcha) GetCaptCha(ctx context.Context) (id, b64s, answer string, err error) {
-	return r.captcha.Generate()
+// contextGetter 支持ctx读取答案的存储，如RedisStore
+type contextGetter interface {
+	GetContext(ctx context.Context, id string, clear bool) (string, error)
 }
 
-func (r *Captcha) Verify(ctx context.Context, id, VerifyValue string) (b bool) {
-	return r.stor.Verify(id, VerifyValue, true)
+func (r *Captcha) set(ctx context.Context, id, answer string) error {
+	if cs, ok := r.stor.(ContextStore); ok {
+		return cs.SetContext(ctx, id, answer)
+	}
+	return r.stor.Set(id, answer)
 }
 
+func (r *Captcha) get(ctx context.Context, id string, clear bool) (string, error) {
+	if g, ok := r.stor.(contextGetter); ok {
+		return g.GetContext(ctx, id, clear)
+	}
+	return r.stor.Get(id, clear), nil
+}
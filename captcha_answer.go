@@ -0,0 +1,43 @@
+package vbasedata
+
+import "strings"
+
+// CaptchaVerifyConfig 答案比较方式，比较前对存储的答案和用户输入做同样的处理
+type CaptchaVerifyConfig struct {
+	IgnoreCase    bool `json:"ignore_case" yaml:"ignore_case"`         // 忽略大小写
+	TrimSpace     bool `json:"trim_space" yaml:"trim_space"`           // 去掉首尾空白
+	FullWidth     bool `json:"full_width" yaml:"full_width"`           // 全角字符转半角，兼容中文输入法
+	KeepOnFailure bool `json:"keep_on_failure" yaml:"keep_on_failure"` // 答错后保留验证码，允许重试到limit.max_failures次，默认答错即作废
+}
+
+// normalize 按配置规范化答案
+func (c CaptchaVerifyConfig) normalize(s string) string {
+	if c.FullWidth {
+		s = toHalfWidth(s)
+	}
+	if c.TrimSpace {
+		s = strings.TrimSpace(s)
+	}
+	if c.IgnoreCase {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
+// match 比较存储的答案和用户输入
+func (c CaptchaVerifyConfig) match(answer, input string) bool {
+	return c.normalize(answer) == c.normalize(input)
+}
+
+// toHalfWidth 全角ASCII(U+FF01-U+FF5E)和全角空格转为半角
+func toHalfWidth(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '　':
+			return ' '
+		case r >= '！' && r <= '～':
+			return r - 0xFEE0
+		}
+		return r
+	}, s)
+}
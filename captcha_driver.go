@@ -0,0 +1,169 @@
+package vbasedata
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/aveyuan/base64Captcha"
+	"github.com/golang/freetype"
+	"github.com/golang/freetype/truetype"
+)
+
+// CaptchaConfig.Type支持的验证码类型
+const (
+	CaptchaTypeDigit   = "digit"   // 数字
+	CaptchaTypeString  = "string"  // 字符，字符集可配置
+	CaptchaTypeChinese = "chinese" // 汉字，需要通过font_dir提供中文字体
+	CaptchaTypeMath    = "math"    // 算术题
+	CaptchaTypeAudio   = "audio"   // 语音数字
+	CaptchaTypeSlider  = "slider"  // 滑块拼图，使用GetInteractive/VerifyPosition
+	CaptchaTypeClick   = "click"   // 按顺序点选图标，使用GetInteractive/VerifyPosition
+)
+
+// CaptchaNoiseConfig 图片干扰配置
+type CaptchaNoiseConfig struct {
+	NoiseCount     int  `json:"noise_count" yaml:"noise_count"`           // 干扰字符数
+	ShowHollowLine bool `json:"show_hollow_line" yaml:"show_hollow_line"` // 空心线
+	ShowSlimeLine  bool `json:"show_slime_line" yaml:"show_slime_line"`   // 细线
+	ShowSineLine   bool `json:"show_sine_line" yaml:"show_sine_line"`     // 正弦线
+}
+
+func (n CaptchaNoiseConfig) lineOptions() int {
+	var opts int
+	if n.ShowHollowLine {
+		opts |= base64Captcha.OptionShowHollowLine
+	}
+	if n.ShowSlimeLine {
+		opts |= base64Captcha.OptionShowSlimeLine
+	}
+	if n.ShowSineLine {
+		opts |= base64Captcha.OptionShowSineLine
+	}
+	return opts
+}
+
+// CaptchaDigitConfig 数字验证码配置
+type CaptchaDigitConfig struct {
+	Length   int     `json:"length" yaml:"length"`       // 位数，默认5
+	MaxSkew  float64 `json:"max_skew" yaml:"max_skew"`   // 数字最大倾斜，默认0.7
+	DotCount int     `json:"dot_count" yaml:"dot_count"` // 干扰点数，默认80
+}
+
+// CaptchaStringConfig 字符验证码配置
+type CaptchaStringConfig struct {
+	CaptchaNoiseConfig `yaml:",inline"`
+	Length             int    `json:"length" yaml:"length"` // 字符数，默认4
+	Source             string `json:"source" yaml:"source"` // 字符集，默认去掉易混淆字符的数字和字母
+}
+
+// CaptchaChineseConfig 汉字验证码配置
+type CaptchaChineseConfig struct {
+	CaptchaNoiseConfig `yaml:",inline"`
+	Length             int    `json:"length" yaml:"length"` // 字数，默认4
+	Source             string `json:"source" yaml:"source"` // 字符集，默认常用汉字
+}
+
+// CaptchaMathConfig 算术验证码配置
+type CaptchaMathConfig struct {
+	CaptchaNoiseConfig `yaml:",inline"`
+}
+
+// CaptchaAudioConfig 语音验证码配置
+type CaptchaAudioConfig struct {
+	Length   int    `json:"length" yaml:"length"`     // 位数，默认6
+	Language string `json:"language" yaml:"language"` // 语言 en/ja/ru/zh，默认en
+}
+
+// loadedFonts 预先加载好的字体，base64Captcha按"fonts/<名称>"查找
+type loadedFonts map[string]*truetype.Font
+
+// LoadFontByName implements base64Captcha.FontsStorage.
+func (s loadedFonts) LoadFontByName(name string) *truetype.Font {
+	return s[name]
+}
+
+// LoadFontsByNames implements base64Captcha.FontsStorage.
+func (s loadedFonts) LoadFontsByNames(names []string) []*truetype.Font {
+	fonts := make([]*truetype.Font, 0, len(names))
+	for _, name := range names {
+		fonts = append(fonts, s[name])
+	}
+	return fonts
+}
+
+// loadCaptchaFonts 加载c.Fonts，设置font_dir时从目录读取，否则使用内置字体，字体缺失或无法解析时返回错误
+func loadCaptchaFonts(c *CaptchaConfig) (loadedFonts, error) {
+	fonts := make(loadedFonts, len(c.Fonts))
+	for _, name := range c.Fonts {
+		var (
+			font *truetype.Font
+			err  error
+		)
+		if c.FontDir != "" {
+			font, err = loadFontFile(filepath.Join(c.FontDir, path.Base(name)))
+		} else {
+			font, err = loadEmbeddedFont("fonts/" + name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		fonts["fonts/"+name] = font
+	}
+	return fonts, nil
+}
+
+// loadEmbeddedFont 内置字体不存在时base64Captcha会panic，这里转为错误
+func loadEmbeddedFont(name string) (font *truetype.Font, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("内置字体%v加载失败: %v", path.Base(name), r)
+		}
+	}()
+	return base64Captcha.DefaultEmbeddedFonts.LoadFontByName(name), nil
+}
+
+func loadFontFile(file string) (*truetype.Font, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("读取字体失败: %w", err)
+	}
+	font, err := freetype.ParseFont(b)
+	if err != nil {
+		return nil, fmt.Errorf("字体%v解析失败: %w", file, err)
+	}
+	return font, nil
+}
+
+// newCaptchaDriver 按Type创建验证码驱动，Type为空时使用math，未知类型返回错误。字体在这里全部加载，不会在生成验证码时panic
+func newCaptchaDriver(c *CaptchaConfig) (base64Captcha.Driver, error) {
+	switch c.Type {
+	case "", CaptchaTypeMath, CaptchaTypeString, CaptchaTypeChinese, CaptchaTypeSlider, CaptchaTypeClick:
+	case CaptchaTypeDigit:
+		o := c.Digit
+		return base64Captcha.NewDriverDigit(c.Height, c.Width, o.Length, o.MaxSkew, o.DotCount), nil
+	case CaptchaTypeAudio:
+		o := c.Audio
+		return base64Captcha.NewDriverAudio(o.Length, o.Language), nil
+	default:
+		return nil, fmt.Errorf("未知的验证码类型:%v", c.Type)
+	}
+
+	fonts, err := loadCaptchaFonts(c)
+	if err != nil {
+		return nil, err
+	}
+	switch c.Type {
+	case CaptchaTypeString:
+		o := c.String
+		return base64Captcha.NewDriverString(c.Height, c.Width, o.NoiseCount, o.lineOptions(), o.Length, o.Source, c.BgColor, fonts, c.Fonts), nil
+	case CaptchaTypeChinese:
+		o := c.Chinese
+		return base64Captcha.NewDriverChinese(c.Height, c.Width, o.NoiseCount, o.lineOptions(), o.Length, o.Source, c.BgColor, fonts, c.Fonts), nil
+	default:
+		// slider/click由GetInteractive生成，不使用这里的驱动
+		o := c.Math
+		return base64Captcha.NewDriverMath(c.Height, c.Width, o.NoiseCount, o.lineOptions(), c.BgColor, fonts, c.Fonts), nil
+	}
+}
@@ -0,0 +1,375 @@
+package vbasedata
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/aveyuan/base64Captcha"
+)
+
+// CaptchaSliderConfig 滑块验证码配置
+type CaptchaSliderConfig struct {
+	PieceSize int `json:"piece_size" yaml:"piece_size"` // 拼图块边长 单位：像素，默认40，宽度需大于3倍边长
+	Tolerance int `json:"tolerance" yaml:"tolerance"`   // 横向允许误差 单位：像素，默认5
+}
+
+// CaptchaClickConfig 点选验证码配置
+type CaptchaClickConfig struct {
+	Count     int `json:"count" yaml:"count"`         // 需要点击的图标数，默认3，最多5
+	IconSize  int `json:"icon_size" yaml:"icon_size"` // 图标边长 单位：像素，默认30
+	Tolerance int `json:"tolerance" yaml:"tolerance"` // 点击位置与图标中心的最大距离 单位：像素，默认15
+}
+
+// CaptchaTrackConfig 拖动轨迹校验配置，轨迹由前端采集后随位置一起提交
+type CaptchaTrackConfig struct {
+	Optional    bool `json:"optional" yaml:"optional"`         // 允许不提交轨迹，不提交时跳过轨迹校验，默认必须提交
+	MinPoints   int  `json:"min_points" yaml:"min_points"`     // 最少采样点数，默认5
+	MinDuration int  `json:"min_duration" yaml:"min_duration"` // 最短耗时 单位：毫秒，默认300
+}
+
+// CaptchaPoint 坐标，原点为背景图左上角
+type CaptchaPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// CaptchaTrackPoint 轨迹采样点，T为相对开始拖动的毫秒数
+type CaptchaTrackPoint struct {
+	X int   `json:"x"`
+	Y int   `json:"y"`
+	T int64 `json:"t"`
+}
+
+// InteractiveCaptcha slider/click验证码，图片均为base64 data uri
+type InteractiveCaptcha struct {
+	Id         string   `json:"id"`
+	Type       string   `json:"type"`
+	Background string   `json:"background"`      // 背景图
+	Piece      string   `json:"piece,omitempty"` // slider: 拼图块，从x=0处开始拖动
+	PieceY     int      `json:"piece_y"`         // slider: 拼图块的纵坐标
+	Hint       string   `json:"hint,omitempty"`  // click: 按点击顺序排列的图标
+	Hints      []string `json:"hints,omitempty"` // click: 按点击顺序的图标名称
+}
+
+// captchaShapes 点选验证码的图标，名称通过InteractiveCaptcha.Hints返回给前端
+var captchaShapes = []string{"circle", "square", "triangle", "diamond", "cross"}
+
+func isInteractiveType(typ string) bool {
+	return typ == CaptchaTypeSlider || typ == CaptchaTypeClick
+}
+
+// isInteractiveAnswer 存储的答案格式为"slider:x"或"click:x1,y1;x2,y2"
+func isInteractiveAnswer(v string) bool {
+	typ, _, ok := strings.Cut(v, ":")
+	return ok && isInteractiveType(typ)
+}
+
+// GetInteractive 生成slider/click验证码，答案保存在Store中，与文字验证码共用存储和失败次数限制
+func (r *Captcha) GetInteractive(ctx context.Context) (*InteractiveCaptcha, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var ic *InteractiveCaptcha
+	var answer string
+	var err error
+	switch r.typ {
+	case CaptchaTypeSlider:
+		ic, answer, err = r.drawSlider()
+	case CaptchaTypeClick:
+		ic, answer, err = r.drawClick()
+	default:
+		return nil, fmt.Errorf("%v类型验证码请使用GetCaptCha", r.typ)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ic.Id = base64Captcha.RandomId()
+	ic.Type = r.typ
+	if err := r.set(ctx, ic.Id, answer); err != nil {
+		return nil, err
+	}
+	return ic, nil
+}
+
+// VerifyPosition 校验slider/click验证码。slider提交一个点，只比较横坐标；click按Hints顺序提交每个图标的点击位置。
+// track为拖动轨迹，用于简单的人机判断，默认必须提交，track.optional为true时可以省略。失败次数限制同VerifyResult
+func (r *Captcha) VerifyPosition(ctx context.Context, id string, points []CaptchaPoint, track []CaptchaTrackPoint, clientKey string) (VerifyResult, error) {
+	return r.verifyWith(ctx, id, clientKey, func(v string) bool {
+		return r.matchPosition(v, points) && r.checkTrack(v, points, track)
+	})
+}
+
+func (r *Captcha) matchPosition(v string, points []CaptchaPoint) bool {
+	typ, data, _ := strings.Cut(v, ":")
+	switch typ {
+	case CaptchaTypeSlider:
+		x, err := strconv.Atoi(data)
+		return err == nil && len(points) == 1 && absInt(points[0].X-x) <= r.slider.Tolerance
+	case CaptchaTypeClick:
+		want, err := parseCaptchaPoints(data)
+		if err != nil || len(want) == 0 || len(points) != len(want) {
+			return false
+		}
+		tol := r.click.Tolerance
+		for i, p := range want {
+			dx, dy := points[i].X-p.X, points[i].Y-p.Y
+			if dx*dx+dy*dy > tol*tol {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// checkTrack 轨迹的简单人机判断：采样点数和耗时不能过少，时间不能倒退，slider的终点要与提交位置一致，
+// 且不能是纵坐标不变的匀速直线(脚本模拟拖动的典型特征)
+func (r *Captcha) checkTrack(v string, points []CaptchaPoint, track []CaptchaTrackPoint) bool {
+	if len(track) == 0 {
+		return r.track.Optional
+	}
+	if len(track) < r.track.MinPoints {
+		return false
+	}
+	first, last := track[0], track[len(track)-1]
+	if last.T-first.T < int64(r.track.MinDuration) {
+		return false
+	}
+	for i := 1; i < len(track); i++ {
+		if track[i].T < track[i-1].T {
+			return false
+		}
+	}
+	if strings.HasPrefix(v, CaptchaTypeSlider+":") && absInt(last.X-points[0].X) > r.slider.Tolerance {
+		return false
+	}
+	return !isUniformLine(track)
+}
+
+// isUniformLine 纵坐标不变且速度恒定，每段位移与匀速时相差不超过1像素(取整误差)
+func isUniformLine(track []CaptchaTrackPoint) bool {
+	first, last := track[0], track[len(track)-1]
+	if last.T == first.T {
+		return true
+	}
+	v := float64(last.X-first.X) / float64(last.T-first.T)
+	for i := 1; i < len(track); i++ {
+		if track[i].Y != first.Y {
+			return false
+		}
+		dx, dt := track[i].X-track[i-1].X, track[i].T-track[i-1].T
+		if math.Abs(float64(dx)-v*float64(dt)) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Captcha) drawSlider() (*InteractiveCaptcha, string, error) {
+	w, h, s := r.width, r.height, r.slider.PieceSize
+	if w <= 3*s || h < s {
+		return nil, "", errors.New("slider拼图块过大，宽度需大于3倍piece_size且高度不小于piece_size")
+	}
+	bg := newCaptchaBackground(w, h)
+	// 缺口不靠近起点，必须拖动一段距离
+	x := 2*s + rand.Intn(w-3*s)
+	y := rand.Intn(h - s + 1)
+	hole := image.Rect(x, y, x+s, y+s)
+
+	piece := image.NewRGBA(image.Rect(0, 0, s, s))
+	draw.Draw(piece, piece.Bounds(), bg, hole.Min, draw.Src)
+	drawBorder(piece, piece.Bounds(), color.NRGBA{R: 255, G: 255, B: 255, A: 220})
+	draw.Draw(bg, hole, image.NewUniform(color.NRGBA{A: 140}), image.Point{}, draw.Over)
+	drawBorder(bg, hole, color.NRGBA{R: 255, G: 255, B: 255, A: 160})
+
+	ic := &InteractiveCaptcha{PieceY: y}
+	var err error
+	if ic.Background, err = encodeCaptchaPNG(bg); err != nil {
+		return nil, "", err
+	}
+	if ic.Piece, err = encodeCaptchaPNG(piece); err != nil {
+		return nil, "", err
+	}
+	return ic, CaptchaTypeSlider + ":" + strconv.Itoa(x), nil
+}
+
+func (r *Captcha) drawClick() (*InteractiveCaptcha, string, error) {
+	w, h, n, size := r.width, r.height, r.click.Count, r.click.IconSize
+	if n > len(captchaShapes) {
+		return nil, "", fmt.Errorf("click图标数不能超过%v", len(captchaShapes))
+	}
+	if w < size || h < size {
+		return nil, "", errors.New("click图标大于背景图")
+	}
+	bg := newCaptchaBackground(w, h)
+	hint := image.NewRGBA(image.Rect(0, 0, n*size, size))
+	draw.Draw(hint, hint.Bounds(), image.White, image.Point{}, draw.Src)
+
+	ic := &InteractiveCaptcha{}
+	answer := make([]string, 0, n)
+	var placed []CaptchaPoint
+	for i, idx := range rand.Perm(len(captchaShapes))[:n] {
+		p, ok := placeCaptchaIcon(w, h, size, placed)
+		if !ok {
+			return nil, "", errors.New("click图标放置失败，请增大图片尺寸或减小icon_size")
+		}
+		placed = append(placed, p)
+		shape := captchaShapes[idx]
+		drawCaptchaShape(bg, shape, p.X, p.Y, size, randCaptchaColor(255))
+		drawCaptchaShape(hint, shape, i*size+size/2, size/2, size*4/5, color.NRGBA{R: 60, G: 60, B: 60, A: 255})
+		ic.Hints = append(ic.Hints, shape)
+		answer = append(answer, fmt.Sprintf("%d,%d", p.X, p.Y))
+	}
+
+	var err error
+	if ic.Background, err = encodeCaptchaPNG(bg); err != nil {
+		return nil, "", err
+	}
+	if ic.Hint, err = encodeCaptchaPNG(hint); err != nil {
+		return nil, "", err
+	}
+	return ic, CaptchaTypeClick + ":" + strings.Join(answer, ";"), nil
+}
+
+// placeCaptchaIcon 随机选取图标中心，与已放置的图标不重叠
+func placeCaptchaIcon(w, h, size int, placed []CaptchaPoint) (CaptchaPoint, bool) {
+	for attempt := 0; attempt < 100; attempt++ {
+		p := CaptchaPoint{X: size/2 + rand.Intn(w-size+1), Y: size/2 + rand.Intn(h-size+1)}
+		ok := true
+		for _, q := range placed {
+			dx, dy := p.X-q.X, p.Y-q.Y
+			if dx*dx+dy*dy < size*size {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return p, true
+		}
+	}
+	return CaptchaPoint{}, false
+}
+
+func parseCaptchaPoints(data string) ([]CaptchaPoint, error) {
+	var points []CaptchaPoint
+	for _, s := range strings.Split(data, ";") {
+		xs, ys, _ := strings.Cut(s, ",")
+		x, err := strconv.Atoi(xs)
+		if err != nil {
+			return nil, err
+		}
+		y, err := strconv.Atoi(ys)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, CaptchaPoint{X: x, Y: y})
+	}
+	return points, nil
+}
+
+// newCaptchaBackground 随机渐变背景加半透明干扰图形
+func newCaptchaBackground(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	c1, c2 := randCaptchaColor(255), randCaptchaColor(255)
+	for x := 0; x < w; x++ {
+		f := float64(x) / float64(w)
+		c := color.RGBA{
+			R: uint8(float64(c1.R)*(1-f) + float64(c2.R)*f),
+			G: uint8(float64(c1.G)*(1-f) + float64(c2.G)*f),
+			B: uint8(float64(c1.B)*(1-f) + float64(c2.B)*f),
+			A: 255,
+		}
+		for y := 0; y < h; y++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	for i := 0; i < 12; i++ {
+		shape := captchaShapes[rand.Intn(len(captchaShapes))]
+		drawCaptchaShape(img, shape, rand.Intn(w), rand.Intn(h), 10+rand.Intn(h/3+1), randCaptchaColor(60))
+	}
+	return img
+}
+
+func randCaptchaColor(alpha uint8) color.NRGBA {
+	return color.NRGBA{R: uint8(rand.Intn(256)), G: uint8(rand.Intn(256)), B: uint8(rand.Intn(256)), A: alpha}
+}
+
+// drawCaptchaShape 以(cx,cy)为中心绘制边长为size的图标
+func drawCaptchaShape(img draw.Image, shape string, cx, cy, size int, c color.Color) {
+	m := shapeMask{shape: shape, cx: cx, cy: cy, r: size / 2}
+	draw.DrawMask(img, m.Bounds(), image.NewUniform(c), image.Point{}, m, m.Bounds().Min, draw.Over)
+}
+
+func drawBorder(img draw.Image, r image.Rectangle, c color.Color) {
+	src := image.NewUniform(c)
+	for _, edge := range []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+1),
+		image.Rect(r.Min.X, r.Max.Y-1, r.Max.X, r.Max.Y),
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+1, r.Max.Y),
+		image.Rect(r.Max.X-1, r.Min.Y, r.Max.X, r.Max.Y),
+	} {
+		draw.Draw(img, edge, src, image.Point{}, draw.Over)
+	}
+}
+
+// shapeMask 图标遮罩，图形内不透明
+type shapeMask struct {
+	shape     string
+	cx, cy, r int
+}
+
+func (m shapeMask) ColorModel() color.Model { return color.AlphaModel }
+
+func (m shapeMask) Bounds() image.Rectangle {
+	return image.Rect(m.cx-m.r, m.cy-m.r, m.cx+m.r+1, m.cy+m.r+1)
+}
+
+func (m shapeMask) At(x, y int) color.Color {
+	if m.contains(x-m.cx, y-m.cy) {
+		return color.Opaque
+	}
+	return color.Transparent
+}
+
+func (m shapeMask) contains(dx, dy int) bool {
+	r := m.r
+	switch m.shape {
+	case "circle":
+		return dx*dx+dy*dy <= r*r
+	case "square":
+		return absInt(dx) <= r*3/4 && absInt(dy) <= r*3/4
+	case "triangle":
+		return dy >= -r && dy <= r && 2*absInt(dx) <= dy+r
+	case "diamond":
+		return absInt(dx)+absInt(dy) <= r
+	case "cross":
+		return (absInt(dx) <= r/3 || absInt(dy) <= r/3) && absInt(dx) <= r && absInt(dy) <= r
+	}
+	return false
+}
+
+func encodeCaptchaPNG(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("data:%s;base64,%s", base64Captcha.MimeTypeImage, base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,123 @@
+package vbasedata
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// humanTrack 模拟拖动到x：纵向抖动，先快后慢
+func humanTrack(x int) []CaptchaTrackPoint {
+	var track []CaptchaTrackPoint
+	for i := 0; i <= 10; i++ {
+		f := float64(i) / 10
+		track = append(track, CaptchaTrackPoint{X: int(float64(x) * (2*f - f*f)), Y: i % 3, T: int64(i * 60)})
+	}
+	track[len(track)-1].X = x
+	return track
+}
+
+func TestCaptcha_Slider(t *testing.T) {
+	ctx := context.Background()
+	stor := NewLruCache(10, time.Minute)
+	c := NewCaptcha(&CaptchaConfig{
+		Type:   CaptchaTypeSlider,
+		Limit:  &CaptchaLimitConfig{MaxFailures: 5},
+		Verify: &CaptchaVerifyConfig{KeepOnFailure: true},
+	}, stor)
+	if _, _, _, err := c.GetCaptCha(ctx); err == nil {
+		t.Fatal("GetCaptCha should reject slider type")
+	}
+	ic, err := c.GetInteractive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(ic.Background, "data:image/png;base64,") || ic.Piece == "" {
+		t.Fatalf("images = %.40q %.40q", ic.Background, ic.Piece)
+	}
+	ans := stor.Get(ic.Id, false)
+	x, err := strconv.Atoi(strings.TrimPrefix(ans, "slider:"))
+	if err != nil {
+		t.Fatalf("answer = %q", ans)
+	}
+
+	if res, _ := c.VerifyResult(ctx, ic.Id, ans, ""); res != VerifyWrong {
+		t.Fatalf("text verify = %v", res)
+	}
+	if res, _ := c.VerifyPosition(ctx, ic.Id, []CaptchaPoint{{X: x + 20}}, humanTrack(x+20), ""); res != VerifyWrong {
+		t.Fatalf("far position = %v", res)
+	}
+	if res, _ := c.VerifyPosition(ctx, ic.Id, []CaptchaPoint{{X: x + 3}}, nil, ""); res != VerifyWrong {
+		t.Fatalf("missing track = %v", res)
+	}
+	var bot []CaptchaTrackPoint
+	for i := 0; i <= 10; i++ {
+		bot = append(bot, CaptchaTrackPoint{X: x * i / 10, Y: 5, T: int64(i * 50)})
+	}
+	if res, _ := c.VerifyPosition(ctx, ic.Id, []CaptchaPoint{{X: x}}, bot, ""); res != VerifyWrong {
+		t.Fatalf("uniform track = %v", res)
+	}
+	if res, _ := c.VerifyPosition(ctx, ic.Id, []CaptchaPoint{{X: x + 3}}, humanTrack(x+3), ""); res != VerifyOK {
+		t.Fatalf("within tolerance = %v", res)
+	}
+}
+
+func TestCaptcha_Click(t *testing.T) {
+	ctx := context.Background()
+	stor := NewLruCache(10, time.Minute)
+	c := NewCaptcha(&CaptchaConfig{Type: CaptchaTypeClick, Verify: &CaptchaVerifyConfig{KeepOnFailure: true}}, stor)
+	ic, err := c.GetInteractive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ic.Hints) != DefaultCaptchaClickCount || ic.Hint == "" {
+		t.Fatalf("hints = %v", ic.Hints)
+	}
+	want, err := parseCaptchaPoints(strings.TrimPrefix(stor.Get(ic.Id, false), "click:"))
+	if err != nil || len(want) != len(ic.Hints) {
+		t.Fatalf("answer = %v %v", want, err)
+	}
+
+	// 默认必须提交轨迹，位置正确也不能通过
+	if res, _ := c.VerifyPosition(ctx, ic.Id, want, nil, ""); res != VerifyWrong {
+		t.Fatalf("missing track = %v", res)
+	}
+	reversed := []CaptchaPoint{want[2], want[1], want[0]}
+	if res, _ := c.VerifyPosition(ctx, ic.Id, reversed, humanTrack(100), ""); res != VerifyWrong {
+		t.Fatalf("wrong order = %v", res)
+	}
+	for i := range want {
+		want[i].X += 5
+		want[i].Y -= 5
+	}
+	if res, _ := c.VerifyPosition(ctx, ic.Id, want, humanTrack(100), ""); res != VerifyOK {
+		t.Fatalf("clicks = %v", res)
+	}
+
+	c = NewCaptcha(&CaptchaConfig{Type: CaptchaTypeClick, Track: &CaptchaTrackConfig{Optional: true}}, stor)
+	ic, _ = c.GetInteractive(ctx)
+	want, _ = parseCaptchaPoints(strings.TrimPrefix(stor.Get(ic.Id, false), "click:"))
+	if res, _ := c.VerifyPosition(ctx, ic.Id, want, nil, ""); res != VerifyOK {
+		t.Fatalf("optional track = %v", res)
+	}
+
+	b := &Bootstrap{Captcha: &CaptchaConfig{Type: CaptchaTypeSlider, Slider: &CaptchaSliderConfig{PieceSize: 200}}}
+	if err := b.Validate(); err == nil || !strings.Contains(err.Error(), "captcha.slider.piece_size") {
+		t.Fatalf("piece too large: %v", err)
+	}
+
+	// 构造时校验尺寸和数量，不在生成时panic
+	for _, cfg := range []*CaptchaConfig{
+		{Type: CaptchaTypeSlider, Slider: &CaptchaSliderConfig{PieceSize: 200}},
+		{Type: CaptchaTypeSlider, Slider: &CaptchaSliderConfig{PieceSize: -1}},
+		{Type: CaptchaTypeClick, Click: &CaptchaClickConfig{Count: -1}},
+		{Type: CaptchaTypeClick, Click: &CaptchaClickConfig{Count: len(captchaShapes) + 1}},
+		{Type: CaptchaTypeClick, Click: &CaptchaClickConfig{IconSize: 1000}},
+	} {
+		if _, err := NewCaptchaE(cfg, stor); err == nil {
+			t.Fatalf("expected error for slider %+v click %+v", cfg.Slider, cfg.Click)
+		}
+	}
+}
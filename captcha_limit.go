@@ -0,0 +1,202 @@
+package vbasedata
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	redis "github.com/redis/go-redis/v9"
+)
+
+// CaptchaLimitConfig 验证码防暴力破解配置
+type CaptchaLimitConfig struct {
+	MaxFailures       int `json:"max_failures" yaml:"max_failures"`               // 单个验证码允许的失败次数，达到后验证码作废，默认3
+	ClientMaxFailures int `json:"client_max_failures" yaml:"client_max_failures"` // 同一客户端(IP/用户)在窗口内允许的失败次数，达到后锁定，默认10
+	Window            int `json:"window" yaml:"window"`                           // 客户端失败计数窗口 单位：秒，默认600
+	Lockout           int `json:"lockout" yaml:"lockout"`                         // 客户端锁定时长 单位：秒，默认900
+}
+
+// VerifyResult 验证码校验结果，零值不代表通过
+type VerifyResult int
+
+const (
+	VerifyOK      VerifyResult = iota + 1 // 正确
+	VerifyWrong                           // 答案错误
+	VerifyExpired                         // 验证码不存在或已过期
+	VerifyLocked                          // 验证码失败次数过多已作废，或客户端被锁定
+)
+
+func (r VerifyResult) String() string {
+	switch r {
+	case VerifyOK:
+		return "ok"
+	case VerifyWrong:
+		return "wrong"
+	case VerifyExpired:
+		return "expired"
+	case VerifyLocked:
+		return "locked"
+	}
+	return "unknown"
+}
+
+// AttemptCounter 失败次数计数器，内存实现用于单实例，多实例部署使用redis实现
+type AttemptCounter interface {
+	// Incr 计数加一并返回当前值，首次计数时设置过期时间window
+	Incr(ctx context.Context, key string, window time.Duration) (int64, error)
+	// Get 返回当前值，不存在或已过期时返回0
+	Get(ctx context.Context, key string) (int64, error)
+	// Reset 清除计数
+	Reset(ctx context.Context, key string) error
+}
+
+type memoryAttempt struct {
+	n       int64
+	expires time.Time
+}
+
+// MemoryAttemptCounter 进程内计数器，超过size时淘汰最久未使用的key，用于按验证码id计数
+type MemoryAttemptCounter struct {
+	mu    sync.Mutex
+	cache *lru.Cache[string, *memoryAttempt]
+}
+
+// NewMemoryAttemptCounter 创建进程内计数器，size为0时使用默认值
+func NewMemoryAttemptCounter(size int) *MemoryAttemptCounter {
+	if size <= 0 {
+		size = DefaultCaptchaStorageLen
+	}
+	cache, _ := lru.New[string, *memoryAttempt](size)
+	return &MemoryAttemptCounter{cache: cache}
+}
+
+// Incr implements AttemptCounter.
+func (s *MemoryAttemptCounter) Incr(_ context.Context, key string, window time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.cache.Get(key)
+	if !ok || time.Now().After(a.expires) {
+		a = &memoryAttempt{expires: time.Now().Add(window)}
+		s.cache.Add(key, a)
+	}
+	a.n++
+	return a.n, nil
+}
+
+// Get implements AttemptCounter.
+func (s *MemoryAttemptCounter) Get(_ context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.cache.Get(key)
+	if !ok || time.Now().After(a.expires) {
+		return 0, nil
+	}
+	return a.n, nil
+}
+
+// Reset implements AttemptCounter.
+func (s *MemoryAttemptCounter) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache.Remove(key)
+	return nil
+}
+
+// ExpiringAttemptCounter 进程内计数器，不按容量淘汰，key只在过期后清除。
+// 用于客户端失败计数和锁定，避免大量验证码id的计数把锁定挤出缓存
+type ExpiringAttemptCounter struct {
+	mu        sync.Mutex
+	items     map[string]*memoryAttempt
+	lastSweep time.Time
+}
+
+// expiringSweepInterval 清理过期key的最小间隔
+const expiringSweepInterval = time.Minute
+
+// NewExpiringAttemptCounter 创建不淘汰的进程内计数器
+func NewExpiringAttemptCounter() *ExpiringAttemptCounter {
+	return &ExpiringAttemptCounter{
+		items:     make(map[string]*memoryAttempt),
+		lastSweep: time.Now(),
+	}
+}
+
+// Incr implements AttemptCounter.
+func (s *ExpiringAttemptCounter) Incr(_ context.Context, key string, window time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= expiringSweepInterval {
+		for k, a := range s.items {
+			if now.After(a.expires) {
+				delete(s.items, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	a, ok := s.items[key]
+	if !ok || now.After(a.expires) {
+		a = &memoryAttempt{expires: now.Add(window)}
+		s.items[key] = a
+	}
+	a.n++
+	return a.n, nil
+}
+
+// Get implements AttemptCounter.
+func (s *ExpiringAttemptCounter) Get(_ context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.items[key]
+	if !ok || time.Now().After(a.expires) {
+		return 0, nil
+	}
+	return a.n, nil
+}
+
+// Reset implements AttemptCounter.
+func (s *ExpiringAttemptCounter) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+	return nil
+}
+
+// incrExpireScript 计数加一，首次计数时设置过期时间
+var incrExpireScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// RedisAttemptCounter 基于redis的计数器，多实例共享
+type RedisAttemptCounter struct {
+	rdb redis.UniversalClient
+}
+
+// NewRedisAttemptCounter 创建redis计数器，key前缀由调用方(Captcha)拼接
+func NewRedisAttemptCounter(rdb redis.UniversalClient) *RedisAttemptCounter {
+	return &RedisAttemptCounter{rdb: rdb}
+}
+
+// Incr implements AttemptCounter.
+func (s *RedisAttemptCounter) Incr(ctx context.Context, key string, window time.Duration) (int64, error) {
+	return incrExpireScript.Run(ctx, s.rdb, []string{key}, window.Milliseconds()).Int64()
+}
+
+// Get implements AttemptCounter.
+func (s *RedisAttemptCounter) Get(ctx context.Context, key string) (int64, error) {
+	n, err := s.rdb.Get(ctx, key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
+
+// Reset implements AttemptCounter.
+func (s *RedisAttemptCounter) Reset(ctx context.Context, key string) error {
+	return s.rdb.Del(ctx, key).Err()
+}
@@ -0,0 +1,173 @@
+package vbasedata
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryAttemptCounter(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryAttemptCounter(10)
+	for i := 1; i <= 3; i++ {
+		if n, _ := c.Incr(ctx, "k", 50*time.Millisecond); n != int64(i) {
+			t.Fatalf("incr = %v, want %v", n, i)
+		}
+	}
+	if n, _ := c.Get(ctx, "k"); n != 3 {
+		t.Fatalf("get = %v", n)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if n, _ := c.Get(ctx, "k"); n != 0 {
+		t.Fatalf("expired get = %v", n)
+	}
+	_, _ = c.Incr(ctx, "k", time.Minute)
+	_ = c.Reset(ctx, "k")
+	if n, _ := c.Get(ctx, "k"); n != 0 {
+		t.Fatalf("reset get = %v", n)
+	}
+}
+
+func TestCaptcha_VerifyResult(t *testing.T) {
+	ctx := context.Background()
+	c := NewCaptcha(&CaptchaConfig{
+		Type:   CaptchaTypeDigit,
+		Limit:  &CaptchaLimitConfig{MaxFailures: 2, ClientMaxFailures: 2},
+		Verify: &CaptchaVerifyConfig{KeepOnFailure: true},
+	}, NewLruCache(10, time.Minute))
+
+	id, _, ans, err := c.GetCaptCha(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := c.VerifyResult(ctx, id, "x", "1.2.3.4"); res != VerifyWrong {
+		t.Fatalf("first wrong = %v", res)
+	}
+	// 答错一次后答案仍然有效
+	if res, _ := c.VerifyResult(ctx, id, ans, "1.2.3.4"); res != VerifyOK {
+		t.Fatalf("correct = %v", res)
+	}
+	if res, _ := c.VerifyResult(ctx, id, ans, "1.2.3.4"); res != VerifyExpired {
+		t.Fatalf("replay = %v", res)
+	}
+
+	// 单个验证码失败次数达到上限后作废
+	id, _, ans, _ = c.GetCaptCha(ctx)
+	_, _ = c.VerifyResult(ctx, id, "x", "")
+	if res, _ := c.VerifyResult(ctx, id, "x", ""); res != VerifyLocked {
+		t.Fatalf("id max failures = %v", res)
+	}
+	if res, _ := c.VerifyResult(ctx, id, ans, ""); res != VerifyLocked {
+		t.Fatalf("after id lock = %v", res)
+	}
+
+	// 通过后清除客户端的失败计数，之后累计失败2次锁定，新验证码也无法通过
+	id, _, _, _ = c.GetCaptCha(ctx)
+	if res, _ := c.VerifyResult(ctx, id, "x", "1.2.3.4"); res != VerifyWrong {
+		t.Fatalf("client first failure = %v", res)
+	}
+	id, _, ans, _ = c.GetCaptCha(ctx)
+	if res, _ := c.VerifyResult(ctx, id, "x", "1.2.3.4"); res != VerifyLocked {
+		t.Fatalf("client max failures = %v", res)
+	}
+	if res, _ := c.VerifyResult(ctx, id, ans, "1.2.3.4"); res != VerifyLocked {
+		t.Fatalf("client locked = %v", res)
+	}
+	if res, _ := c.VerifyResult(ctx, id, ans, "5.6.7.8"); res != VerifyOK {
+		t.Fatalf("other client = %v", res)
+	}
+	if res, _ := c.VerifyResult(ctx, "missing", "x", ""); res != VerifyExpired {
+		t.Fatalf("missing = %v", res)
+	}
+}
+
+func TestCaptcha_VerifyConcurrent(t *testing.T) {
+	ctx := context.Background()
+	c := NewCaptcha(&CaptchaConfig{
+		Type:  CaptchaTypeDigit,
+		Limit: &CaptchaLimitConfig{MaxFailures: 3},
+	}, NewLruCache(10, time.Minute))
+	id, _, ans, err := c.GetCaptCha(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 同时提交30个猜测，其中一个正确，最多MaxFailures个请求能进入比较
+	var (
+		wg       sync.WaitGroup
+		compared atomic.Int32
+		ok       atomic.Int32
+	)
+	for i := 0; i < 30; i++ {
+		guess := fmt.Sprintf("x%d", i)
+		if i == 29 {
+			guess = ans
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, _ := c.verifyWith(ctx, id, "", func(v string) bool {
+				compared.Add(1)
+				return v == guess
+			})
+			if res == VerifyOK {
+				ok.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := compared.Load(); n > 3 {
+		t.Fatalf("%d guesses were compared, want at most 3", n)
+	}
+	if ok.Load() > 1 {
+		t.Fatalf("answer accepted %d times", ok.Load())
+	}
+}
+
+func TestExpiringAttemptCounter(t *testing.T) {
+	ctx := context.Background()
+	c := NewExpiringAttemptCounter()
+	if n, _ := c.Incr(ctx, "lock", time.Minute); n != 1 {
+		t.Fatalf("incr = %v", n)
+	}
+	// 大量其他key不会挤掉已有的计数
+	for i := 0; i < 2*DefaultCaptchaStorageLen; i++ {
+		_, _ = c.Incr(ctx, fmt.Sprintf("k%d", i), time.Minute)
+	}
+	if n, _ := c.Get(ctx, "lock"); n != 1 {
+		t.Fatalf("get = %v", n)
+	}
+	_, _ = c.Incr(ctx, "short", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	c.lastSweep = time.Now().Add(-expiringSweepInterval)
+	_, _ = c.Incr(ctx, "lock", time.Minute)
+	if _, ok := c.items["short"]; ok {
+		t.Fatal("expired key should be swept")
+	}
+}
+
+func TestRedisAttemptCounter(t *testing.T) {
+	rdb := newTestRedis(t)
+	ctx := context.Background()
+	c := NewRedisAttemptCounter(rdb)
+	key := fmt.Sprintf("vbtest:%d:attempt", time.Now().UnixNano())
+	defer c.Reset(ctx, key)
+	for i := 1; i <= 3; i++ {
+		if n, err := c.Incr(ctx, key, time.Minute); err != nil || n != int64(i) {
+			t.Fatalf("incr = %v %v", n, err)
+		}
+	}
+	if ttl := rdb.PTTL(ctx, key).Val(); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("ttl = %v", ttl)
+	}
+	if n, _ := c.Get(ctx, key); n != 3 {
+		t.Fatalf("get = %v", n)
+	}
+	_ = c.Reset(ctx, key)
+	if n, err := c.Get(ctx, key); err != nil || n != 0 {
+		t.Fatalf("reset get = %v %v", n, err)
+	}
+}
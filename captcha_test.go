@@ -3,6 +3,7 @@ package vbasedata
 import (
 	"context"
 	"log"
+	"strings"
 	"testing"
 	"time"
 )
@@ -14,3 +15,95 @@ func TestCaptcha(t *testing.T) {
 	log.Print(c.Verify(context.Background(), id, ans))
 
 }
+
+func TestCaptcha_CanceledContext(t *testing.T) {
+	c := NewCaptcha(&CaptchaConfig{}, NewLruCache(2, 3*time.Second))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, _, err := c.GetCaptCha(ctx); err == nil {
+		t.Fatal("expected error for canceled context")
+	}
+	id, _, ans, err := c.GetCaptCha(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Verify(ctx, id, ans) {
+		t.Fatal("verify should fail for canceled context")
+	}
+	if !c.Verify(context.Background(), id, ans) {
+		t.Fatal("verify should succeed")
+	}
+}
+
+func TestCaptcha_Types(t *testing.T) {
+	for _, typ := range []string{CaptchaTypeDigit, CaptchaTypeString, CaptchaTypeMath, CaptchaTypeAudio} {
+		c := NewCaptcha(&CaptchaConfig{
+			Type:   typ,
+			String: &CaptchaStringConfig{Length: 6, Source: "abc123"},
+		}, NewLruCache(10, time.Minute))
+		id, b64s, ans, err := c.GetCaptCha(context.Background())
+		if err != nil || b64s == "" {
+			t.Fatalf("%v: %v", typ, err)
+		}
+		if typ == CaptchaTypeString && (len(ans) != 6 || strings.Trim(ans, "abc123") != "") {
+			t.Fatalf("string answer = %q", ans)
+		}
+		if !c.Verify(context.Background(), id, ans) {
+			t.Fatalf("%v: verify failed", typ)
+		}
+	}
+
+	for _, cfg := range []*CaptchaConfig{
+		{FontDir: t.TempDir(), Fonts: []string{"missing.ttf"}},
+		{Type: CaptchaTypeString, Fonts: []string{"missing.ttf"}},
+	} {
+		if _, err := NewCaptchaE(cfg, NewLruCache(10, time.Minute)); err == nil || !strings.Contains(err.Error(), "missing.ttf") {
+			t.Fatalf("missing font: %v", err)
+		}
+	}
+
+	if _, err := NewCaptchaE(&CaptchaConfig{Type: "emoji"}, NewLruCache(10, time.Minute)); err == nil || !strings.Contains(err.Error(), "emoji") {
+		t.Fatalf("unknown type: %v", err)
+	}
+
+	b := &Bootstrap{Captcha: &CaptchaConfig{Type: CaptchaTypeChinese}}
+	if err := b.Validate(); err == nil || !strings.Contains(err.Error(), "captcha.font_dir") {
+		t.Fatalf("chinese without font_dir: %v", err)
+	}
+	b.Captcha = &CaptchaConfig{Type: "emoji", FontDir: t.TempDir(), Fonts: []string{"missing.ttf"}}
+	if err := b.Validate(); err == nil || !strings.Contains(err.Error(), "captcha.type") || !strings.Contains(err.Error(), "captcha.fonts") {
+		t.Fatalf("invalid captcha config: %v", err)
+	}
+}
+
+func TestCaptcha_VerifyNormalize(t *testing.T) {
+	ctx := context.Background()
+	stor := NewLruCache(10, time.Minute)
+	c := NewCaptcha(&CaptchaConfig{
+		Verify: &CaptchaVerifyConfig{IgnoreCase: true, TrimSpace: true, FullWidth: true},
+	}, stor)
+	for _, input := range []string{" aB3 ", "ＡＢ３", "　ａｂ3"} {
+		_ = stor.Set("id", "Ab3")
+		if res, _ := c.VerifyResult(ctx, "id", input, ""); res != VerifyOK {
+			t.Fatalf("%q: %v", input, res)
+		}
+	}
+
+	c = NewCaptcha(&CaptchaConfig{}, stor)
+	_ = stor.Set("id", "Ab3")
+	if res, _ := c.VerifyResult(ctx, "id", "ab3", ""); res != VerifyWrong {
+		t.Fatalf("exact match = %v", res)
+	}
+	if stor.Get("id", false) != "" {
+		t.Fatal("wrong answer should remove id by default")
+	}
+
+	c = NewCaptcha(&CaptchaConfig{Verify: &CaptchaVerifyConfig{KeepOnFailure: true}}, stor)
+	_ = stor.Set("id", "Ab3")
+	if res, _ := c.VerifyResult(ctx, "id", "ab3", ""); res != VerifyWrong {
+		t.Fatalf("keep on failure = %v", res)
+	}
+	if stor.Get("id", false) == "" {
+		t.Fatal("keep_on_failure should keep id")
+	}
+}
@@ -0,0 +1,478 @@
+package vbasedata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aveyuan/vlogger"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix 环境变量覆盖的前缀，变量名为 VB_<部分>_<字段>，嵌套字段继续用下划线拼接，如VB_GORM_ADDRESS、VB_GORM_CONNS_MAXOPEN
+const EnvPrefix = "VB"
+
+// Bootstrap 配置根，未配置的部分为nil，不做初始化
+type Bootstrap struct {
+	App     *App           `yaml:"app" json:"app"`
+	Http    *Http          `yaml:"http" json:"http"`
+	Grpc    *Grpc          `yaml:"grpc" json:"grpc"`
+	Gorm    *GormConfig    `yaml:"gorm" json:"gorm"`
+	Gorms   []*GormConfig  `yaml:"gorms" json:"gorms"` // 多数据库，按name区分，通过GormManager获取
+	Redis   *RedisConfig   `yaml:"redis" json:"redis"`
+	Pond    *PondConfig    `yaml:"pond" json:"pond"`
+	Captcha *CaptchaConfig `yaml:"captcha" json:"captcha"`
+	Email   *EmailConfig   `yaml:"email" json:"email"`
+	Idgen   *IdgenConfig   `yaml:"idgen" json:"idgen"`
+}
+
+// LoadBootstrap 加载配置，依次为：配置文件(.json按json解析，其余按yaml，path为空时跳过)、环境变量覆盖、默认值、校验。
+// 校验失败时返回所有问题
+func LoadBootstrap(path string) (*Bootstrap, error) {
+	b := &Bootstrap{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			err = json.Unmarshal(data, b)
+		} else {
+			err = yaml.Unmarshal(data, b)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("配置文件%v解析失败: %w", path, err)
+		}
+	}
+	if err := applyEnv(b, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	b.ApplyDefaults()
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// applyEnv 用环境变量覆盖配置，未配置的部分在有对应变量时创建
+func applyEnv(b *Bootstrap, lookup func(string) (string, bool)) error {
+	_, errs := applyEnvStruct(reflect.ValueOf(b).Elem(), EnvPrefix, lookup)
+	return errors.Join(errs...)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func applyEnvStruct(v reflect.Value, prefix string, lookup func(string) (string, bool)) (bool, []error) {
+	var set bool
+	var errs []error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" && !f.Anonymous {
+			name = strings.ToLower(f.Name)
+		}
+		key := prefix + "_" + strings.ToUpper(name)
+		fv := v.Field(i)
+
+		switch {
+		case fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct:
+			target := fv
+			if fv.IsNil() {
+				target = reflect.New(fv.Type().Elem())
+			}
+			ok, e := applyEnvStruct(target.Elem(), key, lookup)
+			errs = append(errs, e...)
+			if ok && fv.IsNil() {
+				fv.Set(target)
+			}
+			set = set || ok
+		case fv.Kind() == reflect.Struct:
+			if f.Anonymous {
+				// 内嵌结构体的字段与外层同级
+				key = prefix
+			}
+			ok, e := applyEnvStruct(fv, key, lookup)
+			errs = append(errs, e...)
+			set = set || ok
+		default:
+			s, ok := lookup(key)
+			if !ok {
+				continue
+			}
+			if err := setEnvValue(fv, s); err != nil {
+				errs = append(errs, fmt.Errorf("环境变量%v: %w", key, err))
+				continue
+			}
+			set = true
+		}
+	}
+	return set, errs
+}
+
+// setEnvValue 按字段类型解析环境变量，切片以逗号分隔，map以k=v,k=v表示
+func setEnvValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("不支持的类型%v", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("不支持的类型%v", v.Type())
+		}
+		m := make(map[string]string)
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			k, val, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("%q 不是k=v格式", item)
+			}
+			m[strings.TrimSpace(k)] = strings.TrimSpace(val)
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("不支持的类型%v", v.Type())
+	}
+	return nil
+}
+
+// configErrors 收集校验问题，字段以配置路径表示
+type configErrors []error
+
+func (e *configErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, fmt.Errorf("%v: %v", field, fmt.Sprintf(format, args...)))
+}
+
+// Validate 校验所有已配置的部分，一次返回全部问题
+func (b *Bootstrap) Validate() error {
+	var errs configErrors
+	if b.App != nil {
+		if b.App.Health != "" && !strings.HasPrefix(b.App.Health, "/") {
+			errs.add("app.health", "路径需以/开头:%v", b.App.Health)
+		}
+		if err := validTraceMode(b.App.Trace); err != nil {
+			errs.add("app.trace", "%v", err)
+		}
+	}
+	if b.Http != nil {
+		validateServer(&errs, "http", b.Http.Addr, b.Http.Timeout, b.Http.TLS)
+		if b.Http.MaxBodySize < 0 {
+			errs.add("http.max_body_size", "不能为负数")
+		}
+	}
+	if b.Grpc != nil {
+		validateServer(&errs, "grpc", b.Grpc.Addr, b.Grpc.Timeout, b.Grpc.TLS)
+		if b.Grpc.MaxMsgSize < 0 {
+			errs.add("grpc.max_msg_size", "不能为负数")
+		}
+	}
+	if b.Gorm != nil {
+		validateGorm(&errs, "gorm", b.Gorm)
+	}
+	names := make(map[string]bool, len(b.Gorms))
+	for i, c := range b.Gorms {
+		prefix := fmt.Sprintf("gorms[%d]", i)
+		if c == nil {
+			errs.add(prefix, "不能为空")
+			continue
+		}
+		if c.Name == "" {
+			errs.add(prefix+".name", "不能为空")
+		} else if names[c.Name] {
+			errs.add(prefix+".name", "重复:%v", c.Name)
+		}
+		names[c.Name] = true
+		validateGorm(&errs, prefix, c)
+	}
+	if b.Redis != nil {
+		validateRedis(&errs, "redis", b.Redis)
+	}
+	if b.Pond != nil {
+		validatePond(&errs, "pond", b.Pond)
+	}
+	if b.Captcha != nil {
+		validateCaptcha(&errs, "captcha", b.Captcha)
+	}
+	if b.Email != nil {
+		validateEmail(&errs, "email", b.Email)
+	}
+	if b.Idgen != nil && b.Idgen.WorkerId > 15 {
+		errs.add("idgen.worker_id", "不能大于15")
+	}
+	return errors.Join(errs...)
+}
+
+func validateServer(errs *configErrors, prefix, addr, timeout string, tlsc *TLSConfig) {
+	if addr != "" {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			errs.add(prefix+".addr", "地址不合法:%v", addr)
+		}
+	}
+	if timeout != "" {
+		if d, err := time.ParseDuration(timeout); err != nil {
+			errs.add(prefix+".timeout", "不是合法的时长:%v", timeout)
+		} else if d < 0 {
+			errs.add(prefix+".timeout", "不能为负数")
+		}
+	}
+	if tlsc != nil && (tlsc.CertFile == "" || tlsc.KeyFile == "") {
+		errs.add(prefix+".tls", "服务端TLS需要cert_file和key_file")
+	}
+}
+
+func validateGorm(errs *configErrors, prefix string, c *GormConfig) {
+	switch c.Type {
+	case "mysql", "pg":
+		if c.DSN == "" && c.Address == "" {
+			errs.add(prefix+".address", "不能为空")
+		}
+		if c.DSN == "" && c.DBName == "" {
+			errs.add(prefix+".db_name", "不能为空")
+		}
+	case "", "sqlite":
+		if len(c.Replicas) > 0 {
+			errs.add(prefix+".replicas", "仅mysql/pg支持只读副本")
+		}
+	default:
+		errs.add(prefix+".type", "未知的数据库类型:%v", c.Type)
+	}
+	switch c.Health {
+	case "", GormHealthCritical, GormHealthDegraded, GormHealthOff:
+	default:
+		errs.add(prefix+".health", "未知的注册方式:%v", c.Health)
+	}
+	if c.Policy != "" {
+		if _, err := newReplicaPolicy(c.Policy); err != nil {
+			errs.add(prefix+".policy", "%v", err)
+		}
+	}
+	if c.TLS != nil {
+		switch c.TLS.VerifyMode {
+		case "", TLSVerifyFull, TLSVerifyCA, TLSVerifySkip:
+		default:
+			errs.add(prefix+".tls.verify_mode", "未知的校验模式:%v", c.TLS.VerifyMode)
+		}
+		if c.Type == "pg" && c.TLS.ServerName != "" {
+			errs.add(prefix+".tls.server_name", "pg不支持，证书按连接地址校验")
+		}
+	}
+	if c.Logconfig != nil {
+		if c.Logconfig.Level != "" {
+			if _, ok := vlogger.LogStr2Level[c.Logconfig.Level]; !ok {
+				errs.add(prefix+".logconfig.level", "未知的日志级别:%v", c.Logconfig.Level)
+			}
+		}
+		if c.Logconfig.SlowThreshold < 0 {
+			errs.add(prefix+".logconfig.slow_threshold", "不能为负数")
+		}
+	}
+	if c.Conns != nil {
+		if c.Conns.Maxidle < 0 || c.Conns.Maxopen < 0 || c.Conns.Maxlifetime < 0 {
+			errs.add(prefix+".conns", "不能为负数")
+		}
+		if c.Conns.Maxopen > 0 && c.Conns.Maxidle > c.Conns.Maxopen {
+			errs.add(prefix+".conns.maxidle", "不能大于maxopen")
+		}
+	}
+	if c.Retry != nil {
+		validateRetry(errs, prefix+".retry", c.Retry)
+	}
+}
+
+func validateRetry(errs *configErrors, prefix string, c *RetryConfig) {
+	if c.MaxAttempts < 0 || c.InitialBackoff < 0 || c.MaxBackoff < 0 || c.Deadline < 0 {
+		errs.add(prefix, "不能为负数")
+	}
+}
+
+func validateRedis(errs *configErrors, prefix string, c *RedisConfig) {
+	if len(c.Addr) == 0 {
+		errs.add(prefix+".addr", "不能为空")
+	}
+	for _, addr := range c.Addr {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			errs.add(prefix+".addr", "地址不合法:%v", addr)
+		}
+	}
+	if c.DB < 0 {
+		errs.add(prefix+".db", "不能为负数")
+	}
+	if c.PoolSize < 0 || c.MaxIdle < 0 || c.ReadTimeout < 0 || c.WriteTimeout < 0 || c.MaxIdleTime < 0 {
+		errs.add(prefix, "连接池和超时配置不能为负数")
+	}
+	if c.Retry != nil {
+		validateRetry(errs, prefix+".retry", c.Retry)
+	}
+}
+
+func validatePond(errs *configErrors, prefix string, c *PondConfig) {
+	if c.MinWorkers < 0 || c.MaxWorkers < 0 || c.MaxCapacity < 0 || c.StopAndWait < 0 {
+		errs.add(prefix, "不能为负数")
+	}
+	if c.MaxWorkers > 0 && c.MinWorkers > c.MaxWorkers {
+		errs.add(prefix+".min_workers", "不能大于max_workers")
+	}
+}
+
+func validateCaptcha(errs *configErrors, prefix string, c *CaptchaConfig) {
+	if c.Width < 0 || c.Height < 0 {
+		errs.add(prefix, "宽高不能为负数")
+	}
+	if c.StorageLen < 0 {
+		errs.add(prefix+".storage_len", "不能为负数")
+	}
+	if c.StroageExp < 0 {
+		errs.add(prefix+".stroage_exp", "不能为负数")
+	}
+	switch c.Type {
+	case "", CaptchaTypeDigit, CaptchaTypeString, CaptchaTypeMath, CaptchaTypeAudio:
+	case CaptchaTypeSlider, CaptchaTypeClick:
+		validateCaptchaInteractive(errs, prefix, c)
+	case CaptchaTypeChinese:
+		if c.FontDir == "" {
+			errs.add(prefix+".font_dir", "chinese类型需要提供中文字体目录")
+		}
+	default:
+		errs.add(prefix+".type", "未知的验证码类型:%v", c.Type)
+	}
+	if c.FontDir != "" {
+		if len(c.Fonts) == 0 {
+			errs.add(prefix+".fonts", "设置font_dir时不能为空")
+		}
+		for _, name := range c.Fonts {
+			if _, err := loadFontFile(filepath.Join(c.FontDir, name)); err != nil {
+				errs.add(prefix+".fonts", "%v", err)
+			}
+		}
+	}
+	if c.Audio != nil {
+		switch c.Audio.Language {
+		case "", "en", "ja", "ru", "zh":
+		default:
+			errs.add(prefix+".audio.language", "不支持的语言:%v", c.Audio.Language)
+		}
+	}
+	if l := c.Limit; l != nil {
+		if l.MaxFailures < 0 {
+			errs.add(prefix+".limit.max_failures", "不能为负数")
+		}
+		if l.ClientMaxFailures < 0 {
+			errs.add(prefix+".limit.client_max_failures", "不能为负数")
+		}
+		if l.Window < 0 {
+			errs.add(prefix+".limit.window", "不能为负数")
+		}
+		if l.Lockout < 0 {
+			errs.add(prefix+".limit.lockout", "不能为负数")
+		}
+	}
+}
+
+// validateCaptchaInteractive 校验slider/click的尺寸和数量，NewCaptchaE也会调用，避免生成时panic
+func validateCaptchaInteractive(errs *configErrors, prefix string, c *CaptchaConfig) {
+	w, h := captchaSize(c)
+	switch c.Type {
+	case CaptchaTypeSlider:
+		if s := c.Slider; s != nil {
+			if s.PieceSize < 0 || s.Tolerance < 0 {
+				errs.add(prefix+".slider", "不能为负数")
+			} else if s.PieceSize > 0 && (w <= 3*s.PieceSize || h < s.PieceSize) {
+				errs.add(prefix+".slider.piece_size", "宽度需大于3倍piece_size且高度不小于piece_size")
+			}
+		}
+	case CaptchaTypeClick:
+		if k := c.Click; k != nil {
+			if k.Count < 0 || k.IconSize < 0 || k.Tolerance < 0 {
+				errs.add(prefix+".click", "不能为负数")
+			}
+			if k.Count > len(captchaShapes) {
+				errs.add(prefix+".click.count", "不能超过%v", len(captchaShapes))
+			}
+			if k.IconSize > w || k.IconSize > h {
+				errs.add(prefix+".click.icon_size", "不能大于图片宽高")
+			}
+		}
+	}
+}
+
+// captchaSize 校验时取未设置时的默认宽高
+func captchaSize(c *CaptchaConfig) (w, h int) {
+	w, h = c.Width, c.Height
+	if w == 0 {
+		w = DefaultCaptchaWidth
+	}
+	if h == 0 {
+		h = DefaultCaptchaHeight
+	}
+	return w, h
+}
+
+func validateEmail(errs *configErrors, prefix string, c *EmailConfig) {
+	if c.Host == "" {
+		errs.add(prefix+".host", "不能为空")
+	}
+	if port, err := strconv.Atoi(c.Port); err != nil || port <= 0 || port > 65535 {
+		errs.add(prefix+".port", "端口不合法:%v", c.Port)
+	}
+	if _, err := mail.ParseAddress(c.Form); err != nil {
+		errs.add(prefix+".form", "发件地址不合法:%v", c.Form)
+	}
+}
@@ -0,0 +1,82 @@
+package vbasedata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadBootstrap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `
+app:
+  app_name: demo
+http:
+  addr: ":8000"
+  timeout: 1s
+gorm:
+  type: mysql
+  address: 127.0.0.1:3306
+  db_name: demo
+  conns:
+    maxopen: 20
+captcha:
+  stroage_exp: 2m
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VB_GORM_ADDRESS", "db:3306")
+	t.Setenv("VB_GORM_PARAMS", "timeout=5s, readTimeout=3s")
+	t.Setenv("VB_GORM_RETRY_MAX_ATTEMPTS", "5")
+	t.Setenv("VB_REDIS_ADDR", "r1:6379,r2:6379")
+
+	b, err := LoadBootstrap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Gorm.Address != "db:3306" || b.Gorm.Params["readTimeout"] != "3s" || b.Gorm.Retry == nil || b.Gorm.Retry.MaxAttempts != 5 {
+		t.Fatalf("env override not applied: %+v", b.Gorm)
+	}
+	if len(b.Redis.Addr) != 2 || b.Redis.Addr[1] != "r2:6379" {
+		t.Fatalf("redis addr = %v", b.Redis.Addr)
+	}
+	if b.Gorm.Conns.Maxopen != 20 || b.Gorm.Conns.Maxidle != DefaultGormMaxIdle {
+		t.Fatalf("conns = %+v", b.Gorm.Conns)
+	}
+	if b.Captcha.StroageExp != 2*time.Minute || b.Captcha.Width != DefaultCaptchaWidth {
+		t.Fatalf("captcha = %+v", b.Captcha)
+	}
+	if b.Pond != nil || b.Email != nil {
+		t.Fatal("unconfigured sections should stay nil")
+	}
+}
+
+func TestBootstrapValidate(t *testing.T) {
+	b := &Bootstrap{
+		Http:  &Http{Addr: ":8000", Timeout: "10"},
+		Gorm:  &GormConfig{Type: "oracle"},
+		Redis: &RedisConfig{},
+		Email: &EmailConfig{Host: "smtp.example.com", Port: "465", Form: "noreply@example.com"},
+	}
+	err := b.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{"http.timeout", "gorm.type", "redis.addr"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "email") {
+		t.Errorf("unexpected email error: %v", err)
+	}
+
+	if err := applyEnv(b, func(key string) (string, bool) {
+		return "abc", key == "VB_POND_MAX_WORKERS"
+	}); err == nil || !strings.Contains(err.Error(), "VB_POND_MAX_WORKERS") {
+		t.Fatalf("applyEnv err = %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package vbasedata
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	gosqlite "github.com/glebarez/go-sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// 数据库错误分类，ClassifyError返回的错误同时包装了分类和原始错误，可以用errors.Is判断
+var (
+	ErrDuplicateKey        = errors.New("duplicate key")
+	ErrForeignKeyViolation = errors.New("foreign key violation")
+	ErrDeadlock            = errors.New("deadlock")
+	ErrConnectionLost      = errors.New("connection lost")
+	ErrTimeout             = errors.New("timeout")
+	ErrNotFound            = errors.New("not found")
+)
+
+// sqlite扩展错误码
+const (
+	sqliteBusy                  = 5
+	sqliteLocked                = 6
+	sqliteConstraintForeignKey  = 787
+	sqliteConstraintPrimaryKey  = 1555
+	sqliteConstraintUnique      = 2067
+	sqlitePrimaryResultCodeMask = 0xff
+)
+
+func mysqlErrNumber(err error) (uint16, bool) {
+	var mysqlErr *mysqldriver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number, true
+	}
+	return 0, false
+}
+
+func pgErrCode(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code, true
+	}
+	return "", false
+}
+
+func sqliteErrCode(err error) (int, bool) {
+	var sqliteErr *gosqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code(), true
+	}
+	return 0, false
+}
+
+// IsDuplicateKey 唯一键/主键冲突
+func IsDuplicateKey(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrDuplicateKey) || errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	if n, ok := mysqlErrNumber(err); ok {
+		return n == 1062 || n == 1586
+	}
+	if code, ok := pgErrCode(err); ok {
+		return code == "23505"
+	}
+	if code, ok := sqliteErrCode(err); ok {
+		return code == sqliteConstraintUnique || code == sqliteConstraintPrimaryKey
+	}
+	return false
+}
+
+// IsForeignKeyViolation 外键约束冲突
+func IsForeignKeyViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrForeignKeyViolation) || errors.Is(err, gorm.ErrForeignKeyViolated) {
+		return true
+	}
+	if n, ok := mysqlErrNumber(err); ok {
+		return n == 1451 || n == 1452 || n == 1216 || n == 1217
+	}
+	if code, ok := pgErrCode(err); ok {
+		return code == "23503"
+	}
+	if code, ok := sqliteErrCode(err); ok {
+		return code == sqliteConstraintForeignKey
+	}
+	return false
+}
+
+// IsDeadlock 死锁，整个事务需要重试
+func IsDeadlock(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrDeadlock) {
+		return true
+	}
+	if n, ok := mysqlErrNumber(err); ok {
+		return n == 1213
+	}
+	if code, ok := pgErrCode(err); ok {
+		return code == "40P01"
+	}
+	if code, ok := sqliteErrCode(err); ok {
+		return code&sqlitePrimaryResultCodeMask == sqliteLocked
+	}
+	return false
+}
+
+// IsConnectionLost 连接断开或无法建立连接
+func IsConnectionLost(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrConnectionLost) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, mysqldriver.ErrInvalidConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	if n, ok := mysqlErrNumber(err); ok {
+		// 2006 server has gone away, 2013 lost connection
+		return n == 2006 || n == 2013
+	}
+	if code, ok := pgErrCode(err); ok {
+		// 08 连接异常，57P01-57P03 服务端关闭
+		return strings.HasPrefix(code, "08") || code == "57P01" || code == "57P02" || code == "57P03"
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && !opErr.Timeout() {
+		return true
+	}
+	return false
+}
+
+// IsTimeout 超时，包括ctx超时、网络超时、锁等待超时和语句超时
+func IsTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	if n, ok := mysqlErrNumber(err); ok {
+		// 1205 lock wait timeout, 3024 max_execution_time
+		return n == 1205 || n == 3024
+	}
+	if code, ok := pgErrCode(err); ok {
+		// 57014 statement_timeout/取消, 55P03 lock_timeout
+		return code == "57014" || code == "55P03"
+	}
+	if code, ok := sqliteErrCode(err); ok {
+		return code&sqlitePrimaryResultCodeMask == sqliteBusy
+	}
+	if pgconn.Timeout(err) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return false
+}
+
+// IsNotFound 记录不存在
+func IsNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrNotFound) || errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, sql.ErrNoRows)
+}
+
+// ClassifyError 将驱动错误映射为分类错误，返回的错误同时包装分类和原始错误，无法分类时原样返回
+func ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var sentinel error
+	switch {
+	case IsNotFound(err):
+		sentinel = ErrNotFound
+	case IsDuplicateKey(err):
+		sentinel = ErrDuplicateKey
+	case IsForeignKeyViolation(err):
+		sentinel = ErrForeignKeyViolation
+	case IsDeadlock(err):
+		sentinel = ErrDeadlock
+	case IsTimeout(err):
+		sentinel = ErrTimeout
+	case IsConnectionLost(err):
+		sentinel = ErrConnectionLost
+	default:
+		return err
+	}
+	if errors.Is(err, sentinel) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
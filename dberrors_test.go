@@ -0,0 +1,68 @@
+package vbasedata
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want error
+	}{
+		{&mysqldriver.MySQLError{Number: 1062}, ErrDuplicateKey},
+		{&mysqldriver.MySQLError{Number: 1452}, ErrForeignKeyViolation},
+		{&mysqldriver.MySQLError{Number: 1213}, ErrDeadlock},
+		{&mysqldriver.MySQLError{Number: 1205}, ErrTimeout},
+		{mysqldriver.ErrInvalidConn, ErrConnectionLost},
+		{&pgconn.PgError{Code: "23505"}, ErrDuplicateKey},
+		{&pgconn.PgError{Code: "23503"}, ErrForeignKeyViolation},
+		{&pgconn.PgError{Code: "40P01"}, ErrDeadlock},
+		{&pgconn.PgError{Code: "57014"}, ErrTimeout},
+		{&pgconn.PgError{Code: "08006"}, ErrConnectionLost},
+		{gorm.ErrRecordNotFound, ErrNotFound},
+	}
+	for _, c := range cases {
+		got := ClassifyError(c.err)
+		if !errors.Is(got, c.want) || !errors.Is(got, c.err) {
+			t.Errorf("ClassifyError(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+
+	plain := errors.New("plain")
+	if ClassifyError(plain) != plain {
+		t.Error("unclassified error should be returned unchanged")
+	}
+}
+
+func TestClassifyError_SQLite(t *testing.T) {
+	db, closeFn, err := NewGorm(&GormConfig{
+		Name:   "errors",
+		DBPath: filepath.Join(t.TempDir(), "errors.db"),
+		SQLite: &SQLiteConfig{ForeignKeys: true},
+	}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFn()
+
+	db.Exec("CREATE TABLE parent (id INTEGER PRIMARY KEY)")
+	db.Exec("CREATE TABLE child (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES parent(id))")
+	db.Exec("INSERT INTO parent (id) VALUES (1)")
+
+	if err := db.Exec("INSERT INTO parent (id) VALUES (1)").Error; !IsDuplicateKey(err) {
+		t.Errorf("expected duplicate key, got %v", err)
+	}
+	if err := db.Exec("INSERT INTO child (id, parent_id) VALUES (1, 2)").Error; !IsForeignKeyViolation(err) {
+		t.Errorf("expected foreign key violation, got %v", err)
+	}
+	var row struct{ ID int }
+	if err := db.Table("parent").Where("id = ?", 3).First(&row).Error; !IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
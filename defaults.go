@@ -0,0 +1,284 @@
+package vbasedata
+
+import (
+	"image/color"
+	"time"
+
+	"github.com/aveyuan/base64Captcha"
+)
+
+// 默认配置，构造函数和Bootstrap.ApplyDefaults都从这里取值，只对未设置(零值)的项生效
+//
+//	server   http/grpc默认启用recovery，其他中间件需要在middleware中开启
+//	gorm     db_path=data.db policy=random health=critical(租户连接池为off)
+//	         logconfig.slow_threshold=3000ms logconfig.ignore_record_not_found_error=true(未配置logconfig时)
+//	         conns.maxidle=5 conns.maxopen=10 conns.maxlifetime=1800s sqlite.reader_conns=4
+//	retry    max_attempts=1 initial_backoff=500ms max_backoff=10000ms
+//	pond     min_workers=2 max_workers=10 stop_and_wait=5s
+//	captcha  type=math width=320 height=120 fonts=[actionj.ttf](未设置font_dir时) bg_color=白色 storage_len=5000 stroage_exp=6m key_prefix=captcha:
+//	         digit.length=5 digit.max_skew=0.7 digit.dot_count=80
+//	         string.length=4 string.source=易区分的数字和字母 chinese.length=4 chinese.source=常用汉字
+//	         audio.length=6 audio.language=en
+//	         slider.piece_size=40 slider.tolerance=5 click.count=3 click.icon_size=30 click.tolerance=15
+//	         track.min_points=5 track.min_duration=300ms(slider/click默认必须提交轨迹)
+//	         verify=精确匹配且答错即作废 limit.max_failures=3 limit.client_max_failures=10 limit.window=600s limit.lockout=900s
+//	tenant   max_tenants=100 idle_timeout=600s close_delay=30s open_timeout=30s
+//	tx       max_retries=3 initial_backoff=20ms max_backoff=1s
+const (
+	DefaultGormDBPath        = "data.db"
+	DefaultGormSlowThreshold = 3000
+	DefaultGormMaxIdle       = 5
+	DefaultGormMaxOpen       = 10
+	DefaultGormMaxLifetime   = 1800
+	DefaultSQLiteReaderConns = 4
+
+	DefaultRetryMaxAttempts    = 1
+	DefaultRetryInitialBackoff = 500
+	DefaultRetryMaxBackoff     = 10000
+
+	DefaultPondMinWorkers  = 2
+	DefaultPondMaxWorkers  = 10
+	DefaultPondStopAndWait = 5
+
+	DefaultCaptchaWidth      = 320
+	DefaultCaptchaHeight     = 120
+	DefaultCaptchaFont       = "actionj.ttf"
+	DefaultCaptchaStorageLen = 5000
+	DefaultCaptchaStroageExp = 6 * time.Minute
+	DefaultCaptchaKeyPrefix  = "captcha:"
+	DefaultCaptchaType       = CaptchaTypeMath
+
+	DefaultCaptchaDigitLength   = 5
+	DefaultCaptchaDigitMaxSkew  = 0.7
+	DefaultCaptchaDigitDotCount = 80
+	DefaultCaptchaStringLength  = 4
+	DefaultCaptchaStringSource  = base64Captcha.TxtSimpleCharaters
+	DefaultCaptchaChineseLength = 4
+	DefaultCaptchaChineseSource = base64Captcha.TxtChineseCharaters
+	DefaultCaptchaAudioLength   = 6
+	DefaultCaptchaAudioLanguage = "en"
+
+	DefaultCaptchaSliderPieceSize  = 40
+	DefaultCaptchaSliderTolerance  = 5
+	DefaultCaptchaClickCount       = 3
+	DefaultCaptchaClickIconSize    = 30
+	DefaultCaptchaClickTolerance   = 15
+	DefaultCaptchaTrackMinPoints   = 5
+	DefaultCaptchaTrackMinDuration = 300
+
+	DefaultCaptchaMaxFailures       = 3
+	DefaultCaptchaClientMaxFailures = 10
+	DefaultCaptchaLimitWindow       = 600
+	DefaultCaptchaLockout           = 900
+
+	DefaultTenantMaxTenants  = 100
+	DefaultTenantIdleTimeout = 600
+	DefaultTenantCloseDelay  = 30
+	DefaultTenantOpenTimeout = 30
+
+	DefaultTxMaxRetries     = 3
+	DefaultTxInitialBackoff = 20 * time.Millisecond
+	DefaultTxMaxBackoff     = time.Second
+)
+
+// setGormDefaults 补全gorm配置的默认值
+func setGormDefaults(c *GormConfig) {
+	if c.Logconfig == nil {
+		c.Logconfig = &Logconfig{
+			IgnoreRecordNotFoundError: true,
+		}
+	}
+	if c.Logconfig.SlowThreshold == 0 {
+		c.Logconfig.SlowThreshold = DefaultGormSlowThreshold
+	}
+	if c.Conns == nil {
+		c.Conns = &Conns{}
+	}
+	if c.Conns.Maxidle == 0 {
+		c.Conns.Maxidle = DefaultGormMaxIdle
+	}
+	if c.Conns.Maxopen == 0 {
+		c.Conns.Maxopen = DefaultGormMaxOpen
+	}
+	if c.Conns.Maxlifetime == 0 {
+		c.Conns.Maxlifetime = DefaultGormMaxLifetime
+	}
+	if c.DBPath == "" {
+		c.DBPath = DefaultGormDBPath
+	}
+	if c.SQLite != nil && c.SQLite.ReaderConns == 0 {
+		c.SQLite.ReaderConns = DefaultSQLiteReaderConns
+	}
+}
+
+// setPondDefaults 补全协程池配置的默认值
+func setPondDefaults(c *PondConfig) {
+	if c.MinWorkers == 0 {
+		c.MinWorkers = DefaultPondMinWorkers
+	}
+	if c.MaxWorkers == 0 {
+		c.MaxWorkers = DefaultPondMaxWorkers
+	}
+	if c.StopAndWait == 0 {
+		c.StopAndWait = DefaultPondStopAndWait
+	}
+}
+
+// setCaptchaDefaults 补全验证码配置的默认值
+func setCaptchaDefaults(c *CaptchaConfig) {
+	if c.Width == 0 {
+		c.Width = DefaultCaptchaWidth
+	}
+	if c.Height == 0 {
+		c.Height = DefaultCaptchaHeight
+	}
+	if c.Type == "" {
+		c.Type = DefaultCaptchaType
+	}
+	if len(c.Fonts) == 0 && c.FontDir == "" {
+		c.Fonts = append(c.Fonts, DefaultCaptchaFont)
+	}
+	if c.BgColor == nil {
+		c.BgColor = &color.RGBA{
+			R: 255,
+			B: 255,
+			G: 255,
+		}
+	}
+	if c.StorageLen == 0 {
+		c.StorageLen = DefaultCaptchaStorageLen
+	}
+	if c.StroageExp == 0 {
+		c.StroageExp = DefaultCaptchaStroageExp
+	}
+	if c.KeyPrefix == "" {
+		c.KeyPrefix = DefaultCaptchaKeyPrefix
+	}
+
+	if c.Digit == nil {
+		c.Digit = &CaptchaDigitConfig{}
+	}
+	if c.Digit.Length == 0 {
+		c.Digit.Length = DefaultCaptchaDigitLength
+	}
+	if c.Digit.MaxSkew == 0 {
+		c.Digit.MaxSkew = DefaultCaptchaDigitMaxSkew
+	}
+	if c.Digit.DotCount == 0 {
+		c.Digit.DotCount = DefaultCaptchaDigitDotCount
+	}
+	if c.String == nil {
+		c.String = &CaptchaStringConfig{}
+	}
+	if c.String.Length == 0 {
+		c.String.Length = DefaultCaptchaStringLength
+	}
+	if c.String.Source == "" {
+		c.String.Source = DefaultCaptchaStringSource
+	}
+	if c.Chinese == nil {
+		c.Chinese = &CaptchaChineseConfig{}
+	}
+	if c.Chinese.Length == 0 {
+		c.Chinese.Length = DefaultCaptchaChineseLength
+	}
+	if c.Chinese.Source == "" {
+		c.Chinese.Source = DefaultCaptchaChineseSource
+	}
+	if c.Math == nil {
+		c.Math = &CaptchaMathConfig{}
+	}
+	if c.Audio == nil {
+		c.Audio = &CaptchaAudioConfig{}
+	}
+	if c.Audio.Length == 0 {
+		c.Audio.Length = DefaultCaptchaAudioLength
+	}
+	if c.Audio.Language == "" {
+		c.Audio.Language = DefaultCaptchaAudioLanguage
+	}
+
+	if c.Slider == nil {
+		c.Slider = &CaptchaSliderConfig{}
+	}
+	if c.Slider.PieceSize == 0 {
+		c.Slider.PieceSize = DefaultCaptchaSliderPieceSize
+	}
+	if c.Slider.Tolerance == 0 {
+		c.Slider.Tolerance = DefaultCaptchaSliderTolerance
+	}
+	if c.Click == nil {
+		c.Click = &CaptchaClickConfig{}
+	}
+	if c.Click.Count == 0 {
+		c.Click.Count = DefaultCaptchaClickCount
+	}
+	if c.Click.IconSize == 0 {
+		c.Click.IconSize = DefaultCaptchaClickIconSize
+	}
+	if c.Click.Tolerance == 0 {
+		c.Click.Tolerance = DefaultCaptchaClickTolerance
+	}
+	if c.Track == nil {
+		c.Track = &CaptchaTrackConfig{}
+	}
+	if c.Track.MinPoints == 0 {
+		c.Track.MinPoints = DefaultCaptchaTrackMinPoints
+	}
+	if c.Track.MinDuration == 0 {
+		c.Track.MinDuration = DefaultCaptchaTrackMinDuration
+	}
+
+	if c.Verify == nil {
+		c.Verify = &CaptchaVerifyConfig{}
+	}
+	if c.Limit == nil {
+		c.Limit = &CaptchaLimitConfig{}
+	}
+	if c.Limit.MaxFailures == 0 {
+		c.Limit.MaxFailures = DefaultCaptchaMaxFailures
+	}
+	if c.Limit.ClientMaxFailures == 0 {
+		c.Limit.ClientMaxFailures = DefaultCaptchaClientMaxFailures
+	}
+	if c.Limit.Window == 0 {
+		c.Limit.Window = DefaultCaptchaLimitWindow
+	}
+	if c.Limit.Lockout == 0 {
+		c.Limit.Lockout = DefaultCaptchaLockout
+	}
+}
+
+// setTenantDefaults 补全多租户配置的默认值
+func setTenantDefaults(c *TenantConfig) {
+	if c.MaxTenants == 0 {
+		c.MaxTenants = DefaultTenantMaxTenants
+	}
+	if c.IdleTimeout == 0 {
+		c.IdleTimeout = DefaultTenantIdleTimeout
+	}
+	if c.CloseDelay == 0 {
+		c.CloseDelay = DefaultTenantCloseDelay
+	}
+	if c.OpenTimeout == 0 {
+		c.OpenTimeout = DefaultTenantOpenTimeout
+	}
+}
+
+// ApplyDefaults 为已配置的各部分补全默认值
+func (b *Bootstrap) ApplyDefaults() {
+	if b.Gorm != nil {
+		setGormDefaults(b.Gorm)
+	}
+	for _, c := range b.Gorms {
+		if c != nil {
+			setGormDefaults(c)
+		}
+	}
+	if b.Pond != nil {
+		setPondDefaults(b.Pond)
+	}
+	if b.Captcha != nil {
+		setCaptchaDefaults(b.Captcha)
+	}
+}
@@ -1,20 +1,24 @@
 package vbasedata
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
+	"net"
+	"net/mail"
 	"net/smtp"
 
 	"github.com/jordan-wright/email"
 )
 
 type EmailConfig struct {
-	Username string `yaml:"username" json:"username"`
-	Password string `yaml:"password" json:"password"`
-	Host     string `yaml:"host" json:"host"`
-	Port     string `yaml:"port" json:"port"`
-	Form     string `yaml:"form" json:"form"`
-	Tls      bool   `yaml:"tls" json:"tls"`
+	Username    string `yaml:"username" json:"username"`
+	Password    string `yaml:"password" json:"password"` // 支持${env:NAME}、${file:/path}等密钥引用，每次发送时读取
+	Host        string `yaml:"host" json:"host"`
+	Port        string `yaml:"port" json:"port"`
+	Form        string `yaml:"form" json:"form"`
+	Tls         bool   `yaml:"tls" json:"tls"`
+	HealthCheck bool   `yaml:"health_check" json:"health_check"` // 向DefaultHealth注册SMTP检查，每次检查会与服务器建立会话，默认不注册
 }
 
 type BodyType string
@@ -25,43 +29,187 @@ const (
 )
 
 type Email struct {
-	e *email.Email
-	c *EmailConfig
+	c  *EmailConfig
+	pw *secretValue
+
+	unregisterHealth func()
 }
 
 func NewEmail(c *EmailConfig) *Email {
-	return &Email{
-		e: email.NewEmail(),
-		c: c,
+	t := &Email{
+		c:  c,
+		pw: newSecretValue(c.Password),
+	}
+	if c.HealthCheck {
+		t.unregisterHealth = DefaultHealth.Register(HealthCheck{
+			Name:  fmt.Sprintf("smtp:%v:%v", c.Host, c.Port),
+			Check: t.Ping,
+		})
+	}
+	return t
+}
+
+// Close 移除注册的健康检查，重复调用无影响
+func (t *Email) Close() {
+	if t.unregisterHealth != nil {
+		t.unregisterHealth()
+		t.unregisterHealth = nil
+	}
+}
+
+// Ping 建立SMTP会话并发送NOOP，用于健康检查
+func (t *Email) Ping(ctx context.Context) error {
+	client, stop, err := t.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	defer client.Close()
+	if err := client.Noop(); err != nil {
+		return err
 	}
+	return client.Quit()
+}
+
+// password 返回SMTP密码，密钥引用按间隔重新读取
+func (t *Email) password(ctx context.Context) (string, error) {
+	if t.pw == nil {
+		return t.c.Password, nil
+	}
+	return t.pw.Get(ctx)
 }
 
 type Msg struct {
 	Title    string
 	Body     string
-	To       string
+	To       string   // 收件人，多个用逗号分隔
+	Cc       []string // 抄送
+	Bcc      []string // 密送，不出现在邮件头中
 	BodyType BodyType // 1 text 2 html
 }
 
 func (t *Email) SendMsg(msg *Msg) error {
-	t.e.From = t.c.Form
-	t.e.To = []string{msg.To}
-	t.e.Subject = msg.Title
+	return t.SendMsgContext(context.Background(), msg)
+}
+
+// SendMsgContext 发送邮件，ctx的超时和取消作用于SMTP拨号和整个会话。
+// 每次发送单独构建邮件，可并发调用；收件人、抄送、密送都会投递
+func (t *Email) SendMsgContext(ctx context.Context, msg *Msg) error {
+	e := email.NewEmail()
+	e.From = t.c.Form
+	e.Subject = msg.Title
+	e.Cc = msg.Cc
+	e.Bcc = msg.Bcc
 	if msg.BodyType == TextBodyType {
-		t.e.Text = []byte(msg.Body)
+		e.Text = []byte(msg.Body)
 	}
 	if msg.BodyType == HtmlBodyType {
-		t.e.HTML = []byte(msg.Body)
+		e.HTML = []byte(msg.Body)
 	}
 
-	if t.c.Tls {
-		return t.e.SendWithTLS(fmt.Sprintf("%v:%v", t.c.Host, t.c.Port), smtp.PlainAuth("", t.c.Username, t.c.Password, t.c.Host), &tls.Config{
-			ServerName:         t.c.Host,
-			InsecureSkipVerify: false,
-		})
+	from, err := mail.ParseAddress(t.c.Form)
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddressList(msg.To)
+	if err != nil {
+		return err
+	}
+	var rcpts []string
+	for _, addr := range to {
+		e.To = append(e.To, addr.String())
+		rcpts = append(rcpts, addr.Address)
+	}
+	for _, v := range append(append([]string{}, msg.Cc...), msg.Bcc...) {
+		addr, err := mail.ParseAddress(v)
+		if err != nil {
+			return err
+		}
+		rcpts = append(rcpts, addr.Address)
+	}
+	raw, err := e.Bytes()
+	if err != nil {
+		return err
+	}
 
+	client, stop, err := t.dial(ctx)
+	if err != nil {
+		return err
 	}
+	defer stop()
+	defer client.Close()
 
-	return t.e.Send(fmt.Sprintf("%v:%v", t.c.Host, t.c.Port), smtp.PlainAuth("", t.c.Username, t.c.Password, t.c.Host))
+	if ok, _ := client.Extension("AUTH"); ok {
+		password, err := t.password(ctx)
+		if err != nil {
+			return err
+		}
+		if err := client.Auth(smtp.PlainAuth("", t.c.Username, password, t.c.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, rcpt := range rcpts {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
 
+// dial 建立SMTP会话，tls为true时直接使用TLS连接，否则在服务端支持时升级STARTTLS。
+// 返回的stop用于解除ctx与连接的绑定
+func (t *Email) dial(ctx context.Context) (*smtp.Client, func() bool, error) {
+	addr := fmt.Sprintf("%v:%v", t.c.Host, t.c.Port)
+	tlsConfig := &tls.Config{
+		ServerName:         t.c.Host,
+		InsecureSkipVerify: false,
+	}
+
+	var conn net.Conn
+	var err error
+	if t.c.Tls {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	// ctx取消时关闭连接，使阻塞的读写立即返回
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+
+	client, err := smtp.NewClient(conn, t.c.Host)
+	if err != nil {
+		stop()
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	if !t.c.Tls {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				stop()
+				_ = client.Close()
+				return nil, nil, err
+			}
+		}
+	}
+	return client, stop, nil
 }
@@ -1,6 +1,13 @@
 package vbasedata
 
 import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -22,3 +29,134 @@ func TestMail(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// fakeSMTP 最简SMTP服务，记录每封邮件的收件人和内容
+type fakeSMTP struct {
+	mu    sync.Mutex
+	rcpts [][]string
+	data  []string
+}
+
+func startFakeSMTP(t *testing.T) (*fakeSMTP, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	s := &fakeSMTP{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s, ln.Addr().(*net.TCPAddr).AddrPort().String()
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+	reply("220 fake")
+	var rcpts []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "NOOP"):
+			reply("250 ok")
+		case strings.HasPrefix(cmd, "RCPT"):
+			rcpts = append(rcpts, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+			reply("250 ok")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, rcpts)
+			s.data = append(s.data, data.String())
+			s.mu.Unlock()
+			rcpts = nil
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 unsupported")
+		}
+	}
+}
+
+func TestEmail_Recipients(t *testing.T) {
+	s, addr := startFakeSMTP(t)
+	host, port, _ := net.SplitHostPort(addr)
+	e := &Email{c: &EmailConfig{Host: host, Port: port, Form: "sender <from@example.com>"}}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- e.SendMsgContext(context.Background(), &Msg{
+				Title:    fmt.Sprintf("msg-%d", i),
+				Body:     "hello",
+				To:       fmt.Sprintf("a%d@example.com, B <b@example.com>", i),
+				Cc:       []string{"cc@example.com"},
+				Bcc:      []string{"bcc@example.com"},
+				BodyType: TextBodyType,
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.rcpts) != 5 {
+		t.Fatalf("sent %d messages", len(s.rcpts))
+	}
+	for i, rcpts := range s.rcpts {
+		data := s.data[i]
+		want := []string{"b@example.com", "cc@example.com", "bcc@example.com"}
+		for _, w := range want {
+			if !slices.Contains(rcpts, w) {
+				t.Fatalf("rcpts %v missing %v", rcpts, w)
+			}
+		}
+		if len(rcpts) != 4 {
+			t.Fatalf("rcpts = %v", rcpts)
+		}
+		// 每封邮件的收件人与标题一一对应，没有被其他并发发送覆盖
+		n := strings.TrimSuffix(strings.TrimPrefix(rcpts[0], "a"), "@example.com")
+		if !strings.Contains(data, "Subject: msg-"+n) {
+			t.Fatalf("message for %v has wrong subject:\n%s", rcpts[0], data)
+		}
+		if strings.Contains(data, "bcc@example.com") {
+			t.Fatal("bcc must not appear in headers")
+		}
+	}
+}
@@ -1,15 +1,21 @@
 package vbasedata
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/go-kratos/kratos/v2/log"
 	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
 	pgconn "github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
 
 	"github.com/aveyuan/vlogger"
 
@@ -19,6 +25,7 @@ import (
 	"gorm.io/gorm"
 	glogger "gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
+	"gorm.io/plugin/dbresolver"
 )
 
 func systemTimeZoneName() string {
@@ -42,21 +49,29 @@ func systemTimeZoneName() string {
 }
 
 func isMySQLUnknownDatabaseErr(err error) bool {
-	var mysqlErr *mysqldriver.MySQLError
-	if errors.As(err, &mysqlErr) {
-		return mysqlErr.Number == 1049
-	}
-	return false
+	n, ok := mysqlErrNumber(err)
+	return ok && n == 1049
 }
 
 func isPGDatabaseDoesNotExistErr(err error) bool {
-	var pgErr *pgconn.PgError
-	if errors.As(err, &pgErr) {
-		return pgErr.Code == "3D000"
+	if code, ok := pgErrCode(err); ok {
+		return code == "3D000"
 	}
 	return strings.Contains(strings.ToLower(err.Error()), "does not exist")
 }
 
+// isMySQLRetryableTxErr 死锁(1213)或锁等待超时(1205)，整个事务可以重试
+func isMySQLRetryableTxErr(err error) bool {
+	n, ok := mysqlErrNumber(err)
+	return ok && (n == 1213 || n == 1205)
+}
+
+// isPGRetryableTxErr 序列化失败(40001)或死锁(40P01)，整个事务可以重试
+func isPGRetryableTxErr(err error) bool {
+	code, ok := pgErrCode(err)
+	return ok && (code == "40001" || code == "40P01")
+}
+
 func quoteMySQLIdent(s string) string {
 	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
 }
@@ -65,9 +80,94 @@ func quotePGIdent(s string) string {
 	return "\"" + strings.ReplaceAll(s, "\"", "\"\"") + "\""
 }
 
-func ensureMySQLDatabase(c *GormConfig, glog *gorm.Config) error {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s)/?charset=utf8&parseTime=True&loc=Local", c.Username, c.Password, c.Address)
-	adminDB, err := gorm.Open(mysql.New(mysql.Config{DSN: dsn}), glog)
+// mysqlDefaultParams 未指定DSN时的默认连接参数
+var mysqlDefaultParams = map[string]string{
+	"charset":   "utf8",
+	"parseTime": "True",
+	"loc":       "Local",
+}
+
+// encodeDsnParams 按key排序编码连接参数，sep为参数之间的分隔符
+func encodeDsnParams(params map[string]string, sep string, escape func(string) string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+escape(params[k]))
+	}
+	return strings.Join(parts, sep)
+}
+
+// buildMySQLDsn 生成mysql连接串，c.DSN不为空时以其为基础，c.Params合并在最后，同名参数以Params为准
+func buildMySQLDsn(c *GormConfig) string {
+	dsn := c.DSN
+	params := make(map[string]string, len(mysqlDefaultParams)+len(c.Params))
+	if dsn == "" {
+		dsn = fmt.Sprintf("%s:%s@tcp(%s)/%s", c.Username, c.Password, c.Address, c.DBName)
+		for k, v := range mysqlDefaultParams {
+			params[k] = v
+		}
+	}
+	if c.TLS != nil {
+		params["tls"] = mysqlTLSName(c)
+	}
+	for k, v := range c.Params {
+		params[k] = v
+	}
+	if len(params) == 0 {
+		return dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn[strings.LastIndex(dsn, "/")+1:], "?") {
+		sep = "&"
+	}
+	return dsn + sep + encodeDsnParams(params, "&", url.QueryEscape)
+}
+
+// rewriteMySQLDsn 解析连接串后修改部分字段，用于副本地址、建库连接和日志脱敏
+func rewriteMySQLDsn(dsn string, fn func(cfg *mysqldriver.Config)) (string, error) {
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	fn(cfg)
+	return cfg.FormatDSN(), nil
+}
+
+// mysqlTLSName 注册到mysql驱动的TLS配置名称
+func mysqlTLSName(c *GormConfig) string {
+	name := c.Name
+	if name == "" {
+		name = c.DBName
+	}
+	if name == "" {
+		name = c.Address
+	}
+	return "vbasedata_" + name
+}
+
+// registerMySQLTLS 将TLS配置注册到mysql驱动，连接串中通过tls=名称引用
+func registerMySQLTLS(c *GormConfig) error {
+	conf, err := c.TLS.ClientConfig()
+	if err != nil {
+		return err
+	}
+	return mysqldriver.RegisterTLSConfig(mysqlTLSName(c), conf)
+}
+
+func ensureMySQLDatabase(ctx context.Context, dsn string, glog *gorm.Config) error {
+	var dbName string
+	adminDsn, err := rewriteMySQLDsn(dsn, func(cfg *mysqldriver.Config) {
+		dbName = cfg.DBName
+		cfg.DBName = ""
+	})
+	if err != nil {
+		return err
+	}
+	adminDB, err := gorm.Open(mysql.New(mysql.Config{DSN: adminDsn}), glog)
 	if err != nil {
 		return err
 	}
@@ -79,23 +179,216 @@ func ensureMySQLDatabase(c *GormConfig, glog *gorm.Config) error {
 		_ = sqlDB.Close()
 	}()
 
-	createSQL := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s DEFAULT CHARACTER SET utf8mb4", quoteMySQLIdent(c.DBName))
-	return adminDB.Exec(createSQL).Error
+	createSQL := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s DEFAULT CHARACTER SET utf8mb4", quoteMySQLIdent(dbName))
+	return adminDB.WithContext(ctx).Exec(createSQL).Error
+}
+
+// newMySQLConn 创建mysql连接池，每次新建连接前从pw重新读取密码
+func newMySQLConn(dsn string, pw *secretValue) (*sql.DB, error) {
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Apply(mysqldriver.BeforeConnect(func(ctx context.Context, cfg *mysqldriver.Config) error {
+		passwd, err := pw.Get(ctx)
+		if err != nil {
+			return err
+		}
+		cfg.Passwd = passwd
+		return nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+	connector, err := mysqldriver.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(connector), nil
 }
 
-func buildPGDsn(host, port, username, password, dbname, sslmode, tz string) string {
+// newPGConn 创建pg连接池，每次新建连接前从pw重新读取密码
+func newPGConn(dsn string, pw *secretValue) (*sql.DB, error) {
+	cfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return stdlib.OpenDB(*cfg, stdlib.OptionBeforeConnect(func(ctx context.Context, cc *pgx.ConnConfig) error {
+		passwd, err := pw.Get(ctx)
+		if err != nil {
+			return err
+		}
+		cc.Password = passwd
+		return nil
+	})), nil
+}
+
+// newMySQLDialector conn不为空时使用已有的连接池
+func newMySQLDialector(dsn string, conn gorm.ConnPool) gorm.Dialector {
+	return mysql.New(mysql.Config{
+		DSN:                       dsn,
+		Conn:                      conn,
+		DefaultStringSize:         256,
+		DisableDatetimePrecision:  true,
+		DontSupportRenameIndex:    true,
+		DontSupportRenameColumn:   true,
+		SkipInitializeWithVersion: false,
+	})
+}
+
+// splitPGAddress 拆分pg的host:port，端口缺省为5432
+func splitPGAddress(address string) (host, port string) {
+	host = address
+	port = "5432"
+	if strings.Contains(address, ":") {
+		parts := strings.Split(address, ":")
+		if len(parts) >= 2 {
+			host = parts[0]
+			if parts[1] != "" {
+				port = parts[1]
+			}
+		}
+	}
+	return host, port
+}
+
+// quotePGDsnValue 按libpq规则给key=value中的值加引号
+func quotePGDsnValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " '\\") {
+		return v
+	}
+	v = strings.ReplaceAll(v, "\\", "\\\\")
+	v = strings.ReplaceAll(v, "'", "\\'")
+	return "'" + v + "'"
+}
+
+// pgSSLMode 未指定sslmode时根据TLS校验模式推导，校验模式未知时返回错误
+func pgSSLMode(c *GormConfig) (string, error) {
+	if c.TLS != nil {
+		if err := c.TLS.checkVerifyMode(); err != nil {
+			return "", err
+		}
+		// pgx按连接的host校验证书，无法单独指定校验名称
+		if c.TLS.ServerName != "" {
+			return "", errors.New("pg不支持tls.server_name，证书按连接地址校验")
+		}
+	}
+	if c.SSLMode != "" {
+		return c.SSLMode, nil
+	}
+	if c.TLS == nil {
+		return "disable", nil
+	}
+	switch c.TLS.verifyMode() {
+	case TLSVerifyCA:
+		return "verify-ca", nil
+	case TLSVerifySkip:
+		return "require", nil
+	default:
+		return "verify-full", nil
+	}
+}
+
+// buildPGConfigDsn 生成pg连接串。c.DSN不为空时以其为基础，设置了TLS或SSLMode时覆盖连接串中的ssl参数，c.Params最后合并
+func buildPGConfigDsn(c *GormConfig) (string, error) {
+	sslmode, err := pgSSLMode(c)
+	if err != nil {
+		return "", err
+	}
+	if c.DSN != "" {
+		params := pgTLSParams(c.TLS)
+		if c.TLS != nil || c.SSLMode != "" {
+			params["sslmode"] = sslmode
+		}
+		for k, v := range c.Params {
+			params[k] = v
+		}
+		return rewritePGDsn(c.DSN, params), nil
+	}
+	tz := c.TimeZone
+	if strings.TrimSpace(tz) == "" || strings.EqualFold(tz, "local") {
+		tz = systemTimeZoneName()
+	}
+	host, port := splitPGAddress(c.Address)
+	return buildPGDsn(host, port, c.Username, c.Password, c.DBName, sslmode, tz, c.TLS, c.Params), nil
+}
+
+// pgTLSParams TLS证书对应的pg连接参数
+func pgTLSParams(t *TLSConfig) map[string]string {
+	params := make(map[string]string)
+	if t == nil {
+		return params
+	}
+	if t.CAFile != "" {
+		params["sslrootcert"] = t.CAFile
+	}
+	if t.CertFile != "" {
+		params["sslcert"] = t.CertFile
+	}
+	if t.KeyFile != "" {
+		params["sslkey"] = t.KeyFile
+	}
+	return params
+}
+
+func buildPGDsn(host, port, username, password, dbname, sslmode, tz string, tlsc *TLSConfig, params map[string]string) string {
 	base := fmt.Sprintf(
 		"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
 		host, username, password, dbname, port, sslmode,
 	)
-	if tz == "" || strings.EqualFold(tz, "local") {
+	if tz != "" && !strings.EqualFold(tz, "local") {
+		base += fmt.Sprintf(" TimeZone=%s", tz)
+	}
+	merged := pgTLSParams(tlsc)
+	for k, v := range params {
+		merged[k] = v
+	}
+	if len(merged) == 0 {
 		return base
 	}
-	return base + fmt.Sprintf(" TimeZone=%s", tz)
+	return base + " " + encodeDsnParams(merged, " ", quotePGDsnValue)
+}
+
+// rewritePGDsn 在连接串上覆盖参数，兼容key=value和postgres://两种格式
+func rewritePGDsn(dsn string, params map[string]string) string {
+	if len(params) == 0 {
+		return dsn
+	}
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err == nil {
+			q := u.Query()
+			for k, v := range params {
+				switch k {
+				case "host":
+					if port := u.Port(); port != "" {
+						u.Host = v + ":" + port
+					} else {
+						u.Host = v
+					}
+				case "port":
+					u.Host = u.Hostname() + ":" + v
+				case "dbname":
+					u.Path = "/" + v
+				default:
+					q.Set(k, v)
+				}
+			}
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	// key=value格式中后出现的同名参数生效
+	return dsn + " " + encodeDsnParams(params, " ", quotePGDsnValue)
 }
 
-func ensurePGDatabase(c *GormConfig, glog *gorm.Config, host, port, sslmode, tz string) error {
-	adminDsn := buildPGDsn(host, port, c.Username, c.Password, "postgres", sslmode, tz)
+func ensurePGDatabase(ctx context.Context, dsn string, glog *gorm.Config) error {
+	cfg, err := pgconn.ParseConfig(dsn)
+	if err != nil {
+		return err
+	}
+	dbName := cfg.Database
+	adminDsn := rewritePGDsn(dsn, map[string]string{"dbname": "postgres"})
 	adminDB, err := gorm.Open(postgres.Open(adminDsn), glog)
 	if err != nil {
 		return err
@@ -108,32 +401,87 @@ func ensurePGDatabase(c *GormConfig, glog *gorm.Config, host, port, sslmode, tz
 		_ = sqlDB.Close()
 	}()
 
+	adminDB = adminDB.WithContext(ctx)
 	var exists int
-	if err := adminDB.Raw("SELECT 1 FROM pg_database WHERE datname = ?", c.DBName).Scan(&exists).Error; err != nil {
+	if err := adminDB.Raw("SELECT 1 FROM pg_database WHERE datname = ?", dbName).Scan(&exists).Error; err != nil {
 		return err
 	}
 	if exists == 1 {
 		return nil
 	}
 
-	createSQL := fmt.Sprintf("CREATE DATABASE %s", quotePGIdent(c.DBName))
+	createSQL := fmt.Sprintf("CREATE DATABASE %s", quotePGIdent(dbName))
 	return adminDB.Exec(createSQL).Error
 }
 
+// buildSQLiteDsn 将sqlite配置转换为驱动的_pragma参数，readOnly用于读连接池
+func buildSQLiteDsn(path string, sc *SQLiteConfig, readOnly bool) string {
+	if sc == nil {
+		return path
+	}
+	q := url.Values{}
+	if sc.JournalMode != "" {
+		q.Add("_pragma", fmt.Sprintf("journal_mode(%s)", sc.JournalMode))
+	}
+	if sc.BusyTimeout > 0 {
+		q.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", sc.BusyTimeout))
+	}
+	if sc.Synchronous != "" {
+		q.Add("_pragma", fmt.Sprintf("synchronous(%s)", sc.Synchronous))
+	}
+	if sc.ForeignKeys {
+		q.Add("_pragma", "foreign_keys(1)")
+	}
+	if sc.CacheSize != 0 {
+		q.Add("_pragma", fmt.Sprintf("cache_size(%d)", sc.CacheSize))
+	}
+	if readOnly {
+		q.Add("_pragma", "query_only(1)")
+	} else if sc.SplitPool {
+		// 写连接开启事务时直接获取写锁，避免读锁升级时的database is locked
+		q.Set("_txlock", "immediate")
+	}
+	if len(q) == 0 {
+		return path
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + q.Encode()
+}
+
 type GormConfig struct {
-	Type      string     `yaml:"type" json:"type"`         //类型 mysql/sqlite
-	DBPath    string     `yaml:"db_path" json:"db_path"`   //数据库路径
-	Name      string     `yaml:"name" json:"name"`         //别名，用来区分多个gorm客户端
-	Username  string     `yaml:"username" json:"username"` // 数据库用户名
-	Password  string     `yaml:"password" json:"password"` // 数据库密码
-	Address   string     `yaml:"address" json:"address"`   // 数据库地址
-	DBName    string     `yaml:"db_name" json:"db_name"`   // 数据库名称
-	SSLMode   string     `yaml:"sslmode" json:"sslmode"`
-	TimeZone  string     `yaml:"timezone" json:"timezone"`
-	Logconfig *Logconfig `yaml:"logconfig" json:"logconfig"` // 日志配置
-	Conns     *Conns     `yaml:"conns" json:"conns"`         // 连接池配置
+	Type       string            `yaml:"type" json:"type"`         //类型 mysql/sqlite
+	DBPath     string            `yaml:"db_path" json:"db_path"`   //数据库路径
+	Name       string            `yaml:"name" json:"name"`         //别名，用来区分多个gorm客户端
+	Username   string            `yaml:"username" json:"username"` // 数据库用户名
+	Password   string            `yaml:"password" json:"password"` // 数据库密码，支持${env:NAME}、${file:/path}等密钥引用，新建连接时按间隔重新读取
+	Address    string            `yaml:"address" json:"address"`   // 数据库地址
+	DBName     string            `yaml:"db_name" json:"db_name"`   // 数据库名称
+	SSLMode    string            `yaml:"sslmode" json:"sslmode"`
+	TimeZone   string            `yaml:"timezone" json:"timezone"`
+	DSN        string            `yaml:"dsn" json:"dsn"`                 // 完整连接串，设置后替代由上面字段生成的连接串
+	Params     map[string]string `yaml:"params" json:"params"`           // 连接参数，合并到连接串中，如mysql的charset/loc/timeout，pg的application_name
+	TLS        *TLSConfig        `yaml:"tls" json:"tls"`                 // TLS配置，mysql注册到驱动，pg映射为sslrootcert/sslcert/sslkey
+	SQLite     *SQLiteConfig     `yaml:"sqlite" json:"sqlite"`           // sqlite配置
+	Trace      bool              `yaml:"trace" json:"trace"`             // 是否开启链路追踪，每条语句创建一个span
+	Retry      *RetryConfig      `yaml:"retry" json:"retry"`             // 启动连接重试配置
+	QueryStats bool              `yaml:"query_stats" json:"query_stats"` // 是否开启语句统计，按指纹聚合次数/耗时/错误，通过GetQueryStats获取报表
+	Replicas   []string          `yaml:"replicas" json:"replicas"`       // 只读副本地址，仅mysql/pg有效，读走副本，写和事务走主库
+	Policy     string            `yaml:"policy" json:"policy"`           // 副本负载均衡策略 random/round_robin，默认random
+	Logconfig  *Logconfig        `yaml:"logconfig" json:"logconfig"`     // 日志配置
+	Conns      *Conns            `yaml:"conns" json:"conns"`             // 连接池配置
+	Health     string            `yaml:"health" json:"health"`           // 向DefaultHealth注册的方式 critical/degraded/off，默认critical即失败时整体为down
 }
 
+// GormConfig.Health 的取值
+const (
+	GormHealthCritical = "critical" // 关键依赖，失败时整体为down
+	GormHealthDegraded = "degraded" // 非关键依赖，失败时整体为degraded
+	GormHealthOff      = "off"      // 不注册
+)
+
 // Logconfig 日志配置
 type Logconfig struct {
 	SlowThreshold             int    `yaml:"slow_threshold" json:"slow_threshold"`                               // 慢 SQL 阈值 单位：毫秒
@@ -142,6 +490,17 @@ type Logconfig struct {
 	Level                     string `yaml:"level" json:"level"`
 }
 
+// SQLiteConfig sqlite配置，未设置的项使用驱动默认值
+type SQLiteConfig struct {
+	JournalMode string `yaml:"journal_mode" json:"journal_mode"` // 日志模式 WAL/DELETE等，并发读写建议WAL
+	BusyTimeout int    `yaml:"busy_timeout" json:"busy_timeout"` // 锁等待超时 单位：毫秒，驱动默认5000
+	Synchronous string `yaml:"synchronous" json:"synchronous"`   // 同步模式 OFF/NORMAL/FULL，WAL下建议NORMAL
+	ForeignKeys bool   `yaml:"foreign_keys" json:"foreign_keys"` // 是否开启外键约束
+	CacheSize   int    `yaml:"cache_size" json:"cache_size"`     // 页缓存大小，正数为页数，负数为KiB
+	SplitPool   bool   `yaml:"split_pool" json:"split_pool"`     // 拆分为单连接写池和多连接读池
+	ReaderConns int    `yaml:"reader_conns" json:"reader_conns"` // 读池连接数，默认4
+}
+
 // Conns 连接池配置
 type Conns struct {
 	Maxidle     int `yaml:"maxidle" json:"maxidle"`         // 最大空闲连接数
@@ -151,40 +510,15 @@ type Conns struct {
 
 // NewGorm 初始化一个gorm的客户端
 func NewGorm(c *GormConfig, logger *log.Helper) (*gorm.DB, func(), error) {
+	return NewGormContext(context.Background(), c, logger)
+}
+
+// NewGormContext 初始化一个gorm的客户端，ctx控制建库、连接检查和重试等待，取消后立即返回
+func NewGormContext(ctx context.Context, c *GormConfig, logger *log.Helper) (*gorm.DB, func(), error) {
 	if c == nil {
 		return nil, nil, errors.New("GORM配置参数不能为空")
 	}
-	//默认配置
-	if c.Logconfig == nil {
-		c.Logconfig = &Logconfig{
-			SlowThreshold:             3000,
-			IgnoreRecordNotFoundError: true,
-		}
-	}
-	if c.Logconfig.SlowThreshold == 0 {
-		c.Logconfig.SlowThreshold = 3000
-	}
-	//默认配置
-	if c.Conns == nil {
-		c.Conns = &Conns{
-			Maxidle:     5,
-			Maxopen:     10,
-			Maxlifetime: 1800,
-		}
-	}
-	if c.Conns.Maxidle == 0 {
-		c.Conns.Maxidle = 5
-	}
-	if c.Conns.Maxopen == 0 {
-		c.Conns.Maxopen = 10
-	}
-	if c.Conns.Maxlifetime == 0 {
-		c.Conns.Maxlifetime = 1800
-	}
-
-	if c.DBPath == "" {
-		c.DBPath = "data.db"
-	}
+	setGormDefaults(c)
 
 	// 设置日志级别
 	l, ok := vlogger.LogStr2Level[c.Logconfig.Level]
@@ -205,111 +539,315 @@ func NewGorm(c *GormConfig, logger *log.Helper) (*gorm.DB, func(), error) {
 		},
 	}
 
+	if c.Type == "mysql" && c.TLS != nil {
+		if err := registerMySQLTLS(c); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// 密码为密钥引用时，新建连接前按间隔重新读取，轮换后的密码对新连接生效
+	pw := newSecretValue(c.Password)
+
+	var db *gorm.DB
+	var resolver *dbresolver.DBResolver
+	err := retryConnect(ctx, c.Retry, logger, fmt.Sprintf("DB:%v", gormPoolName(c)), func(ctx context.Context) error {
+		var err error
+		db, resolver, err = openGorm(ctx, c, glog, pw)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c.Type == "mysql" {
+		masked, _ := rewriteMySQLDsn(buildMySQLDsn(c), func(cfg *mysqldriver.Config) {
+			cfg.Passwd = "******"
+		})
+		logger.Infof("数据库配置:%v", fmt.Sprintf("%s 连接成功", masked))
+	} else if c.Type == "pg" {
+		if c.DSN != "" {
+			if cfg, err := pgconn.ParseConfig(c.DSN); err == nil {
+				logger.Infof("数据库配置:%v", fmt.Sprintf("%s:******@%s:%d/%s 连接成功", cfg.User, cfg.Host, cfg.Port, cfg.Database))
+			}
+		} else {
+			logger.Infof("数据库配置:%v", fmt.Sprintf("%s:******@%s/%s 连接成功", c.Username, c.Address, c.DBName))
+		}
+	} else {
+		logger.Infof("数据库配置:%v", fmt.Sprintf("%s:连接成功", c.DBPath))
+	}
+
+	// SetMaxIdleConns 用于设置连接池中空闲连接的最大数量。
+	sqlDB.SetMaxIdleConns(c.Conns.Maxidle)
+	// SetMaxOpenConns 设置打开数据库连接的最大数量。
+	sqlDB.SetMaxOpenConns(c.Conns.Maxopen)
+	// SetConnMaxLifetime 设置了连接可复用的最大时间。
+	sqlDB.SetConnMaxLifetime(time.Second * time.Duration(c.Conns.Maxlifetime))
+	if c.Type != "mysql" && c.Type != "pg" && resolver != nil {
+		// sqlite同一时刻只允许一个写入，写池固定为单连接
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		logger.Infof("sqlite:%v 已拆分读写连接池", c.DBPath)
+	} else if resolver != nil {
+		// 副本连接池使用同样的配置
+		resolver.SetMaxIdleConns(c.Conns.Maxidle).
+			SetMaxOpenConns(c.Conns.Maxopen).
+			SetConnMaxLifetime(time.Second * time.Duration(c.Conns.Maxlifetime))
+		logger.Infof("数据库副本:%v 已启用读写分离", c.Replicas)
+	}
+	poolName := registerGormPool(gormPoolName(c), sqlDB)
+	if poolName != gormPoolName(c) {
+		logger.Warnf("DB 连接池名称:%v 已存在，使用:%v", gormPoolName(c), poolName)
+	}
+	var replicaKeys []string
+	var replicaDBs []*sql.DB
+	if resolver != nil {
+		_ = resolver.Call(func(connPool gorm.ConnPool) error {
+			if replica, ok := connPool.(*sql.DB); ok && replica != sqlDB {
+				replicaDBs = append(replicaDBs, replica)
+			}
+			return nil
+		})
+		role := GormRoleReplica
+		if c.Type != "mysql" && c.Type != "pg" {
+			role = GormRoleReader
+		}
+		replicaKeys = registerGormReplicaPools(poolName, role, replicaDBs)
+	}
+	unregisterHealth := func() {}
+	if c.Health != GormHealthOff {
+		unregisterHealth = DefaultHealth.Register(HealthCheck{
+			Name:     "db:" + poolName,
+			Critical: c.Health != GormHealthDegraded,
+			Check:    sqlDB.PingContext,
+		})
+	}
+	theF := func() {
+		logger.Infof("DB 连接池关闭-%v", c.DBName)
+		unregisterHealth()
+		unregisterGormPool(poolName, sqlDB)
+		for i, key := range replicaKeys {
+			unregisterGormPool(key, replicaDBs[i])
+		}
+		if err := closeGorm(db, resolver); err != nil {
+			logger.Errorf("DB 连接池关闭失败-%v", c.DBName)
+		}
+	}
+	return db, theF, nil
+}
+
+// openGorm 按配置打开数据库并检查连通性，失败时关闭已打开的连接池
+func openGorm(ctx context.Context, c *GormConfig, glog *gorm.Config, pw *secretValue) (*gorm.DB, *dbresolver.DBResolver, error) {
 	var db *gorm.DB
 	var err error
+	var replicas []gorm.Dialector
+	// 副本等额外打开的连接池，注册到resolver之前失败时需要单独关闭
+	var extraConns []*sql.DB
+	closeExtra := func() {
+		for _, conn := range extraConns {
+			_ = conn.Close()
+		}
+	}
+
+	if pw != nil {
+		passwd, err := pw.Get(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		rc := *c
+		rc.Password = passwd
+		c = &rc
+	}
 
 	if c.Type == "mysql" {
-		dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8&parseTime=True&loc=Local", c.Username, c.Password, c.Address, c.DBName)
-		db, err = gorm.Open(mysql.New(mysql.Config{
-			DSN:                       dsn,
-			DefaultStringSize:         256,
-			DisableDatetimePrecision:  true,
-			DontSupportRenameIndex:    true,
-			DontSupportRenameColumn:   true,
-			SkipInitializeWithVersion: false,
-		}), glog)
+		dsn := buildMySQLDsn(c)
+		db, err = openMySQL(dsn, pw, glog)
 		if err != nil {
 			if isMySQLUnknownDatabaseErr(err) {
-				if err2 := ensureMySQLDatabase(c, glog); err2 != nil {
+				if err2 := ensureMySQLDatabase(ctx, dsn, glog); err2 != nil {
 					return nil, nil, err2
 				}
-				db, err = gorm.Open(mysql.New(mysql.Config{
-					DSN:                       dsn,
-					DefaultStringSize:         256,
-					DisableDatetimePrecision:  true,
-					DontSupportRenameIndex:    true,
-					DontSupportRenameColumn:   true,
-					SkipInitializeWithVersion: false,
-				}), glog)
+				db, err = openMySQL(dsn, pw, glog)
 			}
 			if err != nil {
 				return nil, nil, err
 			}
 		}
-	} else if c.Type == "pg" {
-		sslmode := c.SSLMode
-		if sslmode == "" {
-			sslmode = "disable"
-		}
-		tz := c.TimeZone
-		if strings.TrimSpace(tz) == "" {
-			tz = systemTimeZoneName()
-		}
-		if strings.EqualFold(tz, "local") {
-			tz = systemTimeZoneName()
-		}
-
-		host := c.Address
-		port := "5432"
-		if strings.Contains(c.Address, ":") {
-			parts := strings.Split(c.Address, ":")
-			if len(parts) >= 2 {
-				host = parts[0]
-				if parts[1] != "" {
-					port = parts[1]
+		for _, addr := range c.Replicas {
+			replicaDsn, err := rewriteMySQLDsn(dsn, func(cfg *mysqldriver.Config) {
+				cfg.Addr = addr
+			})
+			if err != nil {
+				_ = closeGorm(db, nil)
+				closeExtra()
+				return nil, nil, err
+			}
+			var conn gorm.ConnPool
+			if pw != nil {
+				replicaConn, err := newMySQLConn(replicaDsn, pw)
+				if err != nil {
+					_ = closeGorm(db, nil)
+					closeExtra()
+					return nil, nil, err
 				}
+				extraConns = append(extraConns, replicaConn)
+				conn = replicaConn
 			}
+			replicas = append(replicas, newMySQLDialector(replicaDsn, conn))
+		}
+	} else if c.Type == "pg" {
+		dsn, err := buildPGConfigDsn(c)
+		if err != nil {
+			return nil, nil, err
 		}
 
-		dsn := buildPGDsn(host, port, c.Username, c.Password, c.DBName, sslmode, tz)
-		db, err = gorm.Open(postgres.Open(dsn), glog)
+		db, err = openPG(dsn, pw, glog)
 		if err != nil {
 			if isPGDatabaseDoesNotExistErr(err) {
-				if err2 := ensurePGDatabase(c, glog, host, port, sslmode, tz); err2 != nil {
+				if err2 := ensurePGDatabase(ctx, dsn, glog); err2 != nil {
 					return nil, nil, err2
 				}
-				db, err = gorm.Open(postgres.Open(dsn), glog)
+				db, err = openPG(dsn, pw, glog)
+			}
+			if err != nil {
+				return nil, nil, err
 			}
+		}
+		for _, addr := range c.Replicas {
+			rhost, rport := splitPGAddress(addr)
+			replicaDsn := rewritePGDsn(dsn, map[string]string{"host": rhost, "port": rport})
+			if pw == nil {
+				replicas = append(replicas, postgres.Open(replicaDsn))
+				continue
+			}
+			replicaConn, err := newPGConn(replicaDsn, pw)
 			if err != nil {
+				_ = closeGorm(db, nil)
+				closeExtra()
 				return nil, nil, err
 			}
+			extraConns = append(extraConns, replicaConn)
+			replicas = append(replicas, postgres.New(postgres.Config{Conn: replicaConn}))
 		}
 	} else {
-		db, err = gorm.Open(sqlite.Open(c.DBPath), glog)
+		db, err = gorm.Open(sqlite.Open(buildSQLiteDsn(c.DBPath, c.SQLite, false)), glog)
 		if err != nil {
 			return nil, nil, err
 		}
+		if c.SQLite != nil && c.SQLite.SplitPool {
+			readerConns := c.SQLite.ReaderConns
+			sqliteReader, err := sql.Open(sqlite.DriverName, buildSQLiteDsn(c.DBPath, c.SQLite, true))
+			if err != nil {
+				_ = closeGorm(db, nil)
+				return nil, nil, err
+			}
+			sqliteReader.SetMaxOpenConns(readerConns)
+			sqliteReader.SetMaxIdleConns(readerConns)
+			extraConns = append(extraConns, sqliteReader)
+			replicas = append(replicas, &sqlite.Dialector{Conn: sqliteReader})
+		}
+	}
+
+	var resolver *dbresolver.DBResolver
+	if len(replicas) > 0 {
+		policy, err := newReplicaPolicy(c.Policy)
+		if err != nil {
+			_ = closeGorm(db, nil)
+			closeExtra()
+			return nil, nil, err
+		}
+		resolver = dbresolver.Register(dbresolver.Config{
+			Replicas: replicas,
+			Policy:   policy,
+		})
+		if err := db.Use(resolver); err != nil {
+			_ = closeGorm(db, nil)
+			closeExtra()
+			return nil, nil, err
+		}
+	}
+
+	if c.Trace {
+		if err := db.Use(NewGormTracing(c, nil)); err != nil {
+			_ = closeGorm(db, resolver)
+			return nil, nil, err
+		}
+	}
+	if c.QueryStats {
+		if err := db.Use(NewQueryStats(time.Duration(c.Logconfig.SlowThreshold) * time.Millisecond)); err != nil {
+			_ = closeGorm(db, resolver)
+			return nil, nil, err
+		}
 	}
 
 	sqlDB, err := db.DB()
 	if err != nil {
+		_ = closeGorm(db, resolver)
 		return nil, nil, err
 	}
-
-	if err := sqlDB.Ping(); err != nil {
-		logger.Errorf("DB:%v PING错误,%v", c.DBName, err)
+	if err := sqlDB.PingContext(ctx); err != nil {
+		_ = closeGorm(db, resolver)
 		return nil, nil, err
-	} else {
-		if c.Type == "mysql" {
-			logger.Infof("数据库配置:%v", fmt.Sprintf("%s:******@tcp(%s)/%s?charset=utf8&parseTime=True&loc=Local 连接成功", c.Username, c.Address, c.DBName))
-		} else if c.Type == "pg" {
-			logger.Infof("数据库配置:%v", fmt.Sprintf("%s:******@%s/%s 连接成功", c.Username, c.Address, c.DBName))
-		} else {
-			logger.Infof("数据库配置:%v", fmt.Sprintf("%s:连接成功", c.DBPath))
-		}
 	}
+	return db, resolver, nil
+}
 
-	// SetMaxIdleConns 用于设置连接池中空闲连接的最大数量。
-	sqlDB.SetMaxIdleConns(c.Conns.Maxidle)
-	// SetMaxOpenConns 设置打开数据库连接的最大数量。
-	sqlDB.SetMaxOpenConns(c.Conns.Maxopen)
-	// SetConnMaxLifetime 设置了连接可复用的最大时间。
-	sqlDB.SetConnMaxLifetime(time.Second * time.Duration(c.Conns.Maxlifetime))
-	theF := func() {
-		logger.Infof("DB 连接池关闭-%v", c.DBName)
-		if err := sqlDB.Close(); err != nil {
-			logger.Errorf("DB 连接池关闭失败-%v", c.DBName)
-		}
+// openMySQL 打开mysql，pw不为空时使用按需读取密码的连接池
+func openMySQL(dsn string, pw *secretValue, glog *gorm.Config) (*gorm.DB, error) {
+	if pw == nil {
+		return gorm.Open(newMySQLDialector(dsn, nil), glog)
 	}
-	return db, theF, nil
+	conn, err := newMySQLConn(dsn, pw)
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(newMySQLDialector(dsn, conn), glog)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// openPG 打开pg，pw不为空时使用按需读取密码的连接池
+func openPG(dsn string, pw *secretValue, glog *gorm.Config) (*gorm.DB, error) {
+	if pw == nil {
+		return gorm.Open(postgres.Open(dsn), glog)
+	}
+	conn, err := newPGConn(dsn, pw)
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), glog)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// closeGorm 关闭主库和所有副本连接池
+func closeGorm(db *gorm.DB, resolver *dbresolver.DBResolver) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	if resolver != nil {
+		_ = resolver.Call(func(connPool gorm.ConnPool) error {
+			if replica, ok := connPool.(*sql.DB); ok && replica != sqlDB {
+				if err := replica.Close(); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			return nil
+		})
+	}
+	if err := sqlDB.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,115 @@
+package vbasedata
+
+import (
+	"strings"
+	"testing"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+func TestBuildMySQLDsn(t *testing.T) {
+	dsn := buildMySQLDsn(&GormConfig{
+		Username: "root",
+		Password: "pass",
+		Address:  "127.0.0.1:3306",
+		DBName:   "app",
+		Params: map[string]string{
+			"charset":     "utf8mb4",
+			"loc":         "UTC",
+			"timeout":     "5s",
+			"readTimeout": "3s",
+			"collation":   "utf8mb4_general_ci",
+		},
+	})
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("parse %q: %v", dsn, err)
+	}
+	if cfg.Loc.String() != "UTC" || !cfg.ParseTime || cfg.Collation != "utf8mb4_general_ci" || cfg.Timeout.String() != "5s" {
+		t.Fatalf("unexpected config from %q: %+v", dsn, cfg)
+	}
+	if !strings.Contains(dsn, "charset=utf8mb4") {
+		t.Fatalf("charset not overridden: %q", dsn)
+	}
+
+	raw := buildMySQLDsn(&GormConfig{
+		DSN:    "u:p@tcp(db:3306)/app?parseTime=true",
+		Params: map[string]string{"loc": "Asia/Shanghai"},
+	})
+	cfg, err = mysqldriver.ParseDSN(raw)
+	if err != nil {
+		t.Fatalf("parse %q: %v", raw, err)
+	}
+	if cfg.Addr != "db:3306" || cfg.Loc.String() != "Asia/Shanghai" {
+		t.Fatalf("unexpected config from %q: %+v", raw, cfg)
+	}
+
+	admin, err := rewriteMySQLDsn(raw, func(cfg *mysqldriver.Config) {
+		cfg.DBName = ""
+	})
+	if err != nil || strings.Contains(admin, "/app") {
+		t.Fatalf("admin dsn %q: %v", admin, err)
+	}
+}
+
+func TestBuildPGDsn(t *testing.T) {
+	dsn := buildPGDsn("db", "5432", "u", "p", "app", "disable", "UTC", nil, map[string]string{
+		"application_name": "my app",
+	})
+	if !strings.HasSuffix(dsn, "TimeZone=UTC application_name='my app'") {
+		t.Fatalf("unexpected dsn %q", dsn)
+	}
+	if got := rewritePGDsn("postgres://u:p@db:5432/app?sslmode=disable", map[string]string{"host": "replica", "dbname": "postgres"}); got != "postgres://u:p@replica:5432/postgres?sslmode=disable" {
+		t.Fatalf("unexpected url dsn %q", got)
+	}
+}
+
+func TestPGTLSDsn(t *testing.T) {
+	c := &GormConfig{TLS: &TLSConfig{CAFile: "/certs/ca.pem", CertFile: "/certs/client.pem", KeyFile: "/certs/client.key", VerifyMode: TLSVerifyCA}}
+	sslmode, err := pgSSLMode(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsn := buildPGDsn("db", "5432", "u", "p", "app", sslmode, "", c.TLS, nil)
+	for _, want := range []string{"sslmode=verify-ca", "sslrootcert=/certs/ca.pem", "sslcert=/certs/client.pem", "sslkey=/certs/client.key"} {
+		if !strings.Contains(dsn, want) {
+			t.Fatalf("%q missing %q", dsn, want)
+		}
+	}
+
+	// DSN方式同样应用TLS配置
+	c.DSN = "postgres://u:p@db:5432/app?sslmode=disable"
+	dsn, err = buildPGConfigDsn(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"sslmode=verify-ca", "sslrootcert=%2Fcerts%2Fca.pem"} {
+		if !strings.Contains(dsn, want) {
+			t.Fatalf("%q missing %q", dsn, want)
+		}
+	}
+}
+
+func TestTLSVerifyMode_Unknown(t *testing.T) {
+	tlsc := &TLSConfig{VerifyMode: "fulll"}
+	if _, err := pgSSLMode(&GormConfig{Type: "pg", TLS: tlsc}); err == nil {
+		t.Fatal("pg should reject unknown verify_mode")
+	}
+	if _, err := buildPGConfigDsn(&GormConfig{Type: "pg", DSN: "host=db", TLS: tlsc}); err == nil {
+		t.Fatal("pg dsn should reject unknown verify_mode")
+	}
+	if err := registerMySQLTLS(&GormConfig{Type: "mysql", Name: "bad", TLS: tlsc}); err == nil {
+		t.Fatal("mysql should reject unknown verify_mode")
+	}
+}
+
+func TestTLSServerName_PG(t *testing.T) {
+	c := &GormConfig{Type: "pg", Address: "db:5432", DBName: "app", TLS: &TLSConfig{ServerName: "db.internal"}}
+	if _, err := buildPGConfigDsn(c); err == nil {
+		t.Fatal("pg should reject server_name")
+	}
+	b := &Bootstrap{Gorm: c}
+	if err := b.Validate(); err == nil || !strings.Contains(err.Error(), "gorm.tls.server_name") {
+		t.Fatalf("validate = %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package vbasedata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"gorm.io/gorm"
+)
+
+// GormManager 多数据库客户端管理，按GormConfig.Name区分
+type GormManager struct {
+	dbs map[string]*gorm.DB
+}
+
+// NewGormManager 按配置依次初始化多个gorm客户端，返回的清理函数会关闭所有连接池
+func NewGormManager(cs []*GormConfig, logger *log.Helper) (*GormManager, func(), error) {
+	return NewGormManagerContext(context.Background(), cs, logger)
+}
+
+// NewGormManagerContext 同NewGormManager，ctx传递给每个客户端的初始化
+func NewGormManagerContext(ctx context.Context, cs []*GormConfig, logger *log.Helper) (*GormManager, func(), error) {
+	if len(cs) == 0 {
+		return nil, nil, errors.New("GORM配置参数不能为空")
+	}
+
+	m := &GormManager{
+		dbs: make(map[string]*gorm.DB, len(cs)),
+	}
+	var cleanups []func()
+	cleanup := func() {
+		// 按打开的相反顺序关闭
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+	}
+
+	for i, c := range cs {
+		if c == nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("第%d个GORM配置参数不能为空", i)
+		}
+		if c.Name == "" {
+			cleanup()
+			return nil, nil, fmt.Errorf("第%d个GORM配置缺少name", i)
+		}
+		if _, ok := m.dbs[c.Name]; ok {
+			cleanup()
+			return nil, nil, fmt.Errorf("GORM配置name重复:%v", c.Name)
+		}
+		db, f, err := NewGormContext(ctx, c, logger)
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("GORM客户端[%v]初始化失败: %w", c.Name, err)
+		}
+		m.dbs[c.Name] = db
+		cleanups = append(cleanups, f)
+	}
+
+	return m, cleanup, nil
+}
+
+// Get 根据名称获取gorm客户端，不存在时返回nil
+func (m *GormManager) Get(name string) *gorm.DB {
+	return m.dbs[name]
+}
+
+// MustGet 根据名称获取gorm客户端，不存在时panic
+func (m *GormManager) MustGet(name string) *gorm.DB {
+	db, ok := m.dbs[name]
+	if !ok {
+		panic(fmt.Sprintf("GORM客户端[%v]不存在", name))
+	}
+	return db
+}
+
+// Names 返回所有客户端名称
+func (m *GormManager) Names() []string {
+	names := make([]string, 0, len(m.dbs))
+	for name := range m.dbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
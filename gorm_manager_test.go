@@ -0,0 +1,36 @@
+package vbasedata
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGormManager(t *testing.T) {
+	dir := t.TempDir()
+	m, closeFn, err := NewGormManager([]*GormConfig{
+		{Name: "orders", DBPath: filepath.Join(dir, "orders.db")},
+		{Name: "users", DBPath: filepath.Join(dir, "users.db")},
+	}, newTestLogger())
+	if err != nil {
+		t.Fatalf("NewGormManager: %v", err)
+	}
+	defer closeFn()
+
+	if m.Get("orders") == nil || m.Get("users") == nil {
+		t.Fatal("expected both clients")
+	}
+	if m.Get("missing") != nil {
+		t.Fatal("expected nil for unknown name")
+	}
+	if err := m.Get("orders").Exec("CREATE TABLE t (id INTEGER)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = NewGormManager([]*GormConfig{
+		{Name: "dup", DBPath: filepath.Join(dir, "a.db")},
+		{Name: "dup", DBPath: filepath.Join(dir, "b.db")},
+	}, newTestLogger())
+	if err == nil {
+		t.Fatal("expected duplicate name error")
+	}
+}
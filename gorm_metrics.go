@@ -0,0 +1,249 @@
+package vbasedata
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+// 连接池角色，作为指标的role标签
+const (
+	GormRolePrimary = "primary" // 主库
+	GormRoleReplica = "replica" // 只读副本
+	GormRoleReader  = "reader"  // sqlite拆分出的读连接池
+)
+
+// gormPool 登记的连接池，name为所属客户端的名称
+type gormPool struct {
+	name string
+	role string
+	db   *sql.DB
+}
+
+// gormPools 记录NewGorm打开的连接池，主库的key为GormConfig.Name，重名时追加序号；副本的key为<名称>/<角色><序号>
+var gormPools = struct {
+	sync.RWMutex
+	m map[string]*gormPool
+}{m: make(map[string]*gormPool)}
+
+// gormPoolName 连接池名称，未设置Name时使用库名或文件路径
+func gormPoolName(c *GormConfig) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if c.Type == "mysql" || c.Type == "pg" {
+		return c.DBName
+	}
+	return c.DBPath
+}
+
+// registerGormPool 登记主库连接池并返回实际使用的名称，名称已被占用时追加#2、#3等序号，不覆盖已有的连接池
+func registerGormPool(name string, db *sql.DB) string {
+	gormPools.Lock()
+	defer gormPools.Unlock()
+	unique := name
+	for i := 2; ; i++ {
+		if _, ok := gormPools.m[unique]; !ok {
+			break
+		}
+		unique = fmt.Sprintf("%s#%d", name, i)
+	}
+	gormPools.m[unique] = &gormPool{name: unique, role: GormRolePrimary, db: db}
+	return unique
+}
+
+// registerGormReplicaPools 登记主库name下的副本/读连接池，返回登记的key
+func registerGormReplicaPools(name, role string, dbs []*sql.DB) []string {
+	gormPools.Lock()
+	defer gormPools.Unlock()
+	keys := make([]string, 0, len(dbs))
+	for i, db := range dbs {
+		key := fmt.Sprintf("%s/%s%d", name, role, i+1)
+		gormPools.m[key] = &gormPool{name: name, role: role, db: db}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func unregisterGormPool(name string, db *sql.DB) {
+	gormPools.Lock()
+	defer gormPools.Unlock()
+	if p, ok := gormPools.m[name]; ok && p.db == db {
+		delete(gormPools.m, name)
+	}
+}
+
+func snapshotGormPools() map[string]*gormPool {
+	gormPools.RLock()
+	defer gormPools.RUnlock()
+	pools := make(map[string]*gormPool, len(gormPools.m))
+	for key, p := range gormPools.m {
+		pools[key] = p
+	}
+	return pools
+}
+
+// GormStats 返回所有已打开连接池的状态，主库的key为GormConfig.Name，副本为<名称>/replica<序号>，sqlite读连接池为<名称>/reader1
+func GormStats() map[string]sql.DBStats {
+	pools := snapshotGormPools()
+	stats := make(map[string]sql.DBStats, len(pools))
+	for key, p := range pools {
+		stats[key] = p.db.Stats()
+	}
+	return stats
+}
+
+// GormHealth 检查所有已打开的连接池，每个连接池单独计算超时，返回所有失败的连接池
+func GormHealth(ctx context.Context, timeout time.Duration) error {
+	pools := snapshotGormPools()
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if err := pingSQLDB(ctx, pools[name].db, timeout); err != nil {
+			errs = append(errs, fmt.Errorf("DB:%v %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Health 检查管理的所有gorm客户端
+func (m *GormManager) Health(ctx context.Context) error {
+	var errs []error
+	for _, name := range m.Names() {
+		sqlDB, err := m.dbs[name].DB()
+		if err == nil {
+			err = pingSQLDB(ctx, sqlDB, 0)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("DB:%v %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PingGorm 带超时检查单个gorm客户端，timeout为0时默认3秒
+func PingGorm(ctx context.Context, db *gorm.DB, timeout time.Duration) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return pingSQLDB(ctx, sqlDB, timeout)
+}
+
+func pingSQLDB(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return db.PingContext(ctx)
+}
+
+// gormCollector 以prometheus指标导出连接池状态，指标名与client_golang的DBStatsCollector保持一致
+type gormCollector struct {
+	maxOpenConnections *prometheus.Desc
+
+	openConnections  *prometheus.Desc
+	inUseConnections *prometheus.Desc
+	idleConnections  *prometheus.Desc
+
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxIdleTimeClosed *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+// NewGormCollector 创建连接池指标采集器，采集时遍历所有已打开的连接池，以db_name和role(primary/replica/reader)标签区分
+func NewGormCollector() prometheus.Collector {
+	labels := []string{"db_name", "role"}
+	return &gormCollector{
+		maxOpenConnections: prometheus.NewDesc(
+			"go_sql_max_open_connections",
+			"Maximum number of open connections to the database.",
+			labels, nil,
+		),
+		openConnections: prometheus.NewDesc(
+			"go_sql_open_connections",
+			"The number of established connections both in use and idle.",
+			labels, nil,
+		),
+		inUseConnections: prometheus.NewDesc(
+			"go_sql_in_use_connections",
+			"The number of connections currently in use.",
+			labels, nil,
+		),
+		idleConnections: prometheus.NewDesc(
+			"go_sql_idle_connections",
+			"The number of idle connections.",
+			labels, nil,
+		),
+		waitCount: prometheus.NewDesc(
+			"go_sql_wait_count_total",
+			"The total number of connections waited for.",
+			labels, nil,
+		),
+		waitDuration: prometheus.NewDesc(
+			"go_sql_wait_duration_seconds_total",
+			"The total time blocked waiting for a new connection.",
+			labels, nil,
+		),
+		maxIdleClosed: prometheus.NewDesc(
+			"go_sql_max_idle_closed_total",
+			"The total number of connections closed due to SetMaxIdleConns.",
+			labels, nil,
+		),
+		maxIdleTimeClosed: prometheus.NewDesc(
+			"go_sql_max_idle_time_closed_total",
+			"The total number of connections closed due to SetConnMaxIdleTime.",
+			labels, nil,
+		),
+		maxLifetimeClosed: prometheus.NewDesc(
+			"go_sql_max_lifetime_closed_total",
+			"The total number of connections closed due to SetConnMaxLifetime.",
+			labels, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *gormCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpenConnections
+	ch <- c.openConnections
+	ch <- c.inUseConnections
+	ch <- c.idleConnections
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdleClosed
+	ch <- c.maxIdleTimeClosed
+	ch <- c.maxLifetimeClosed
+}
+
+// Collect implements prometheus.Collector.
+func (c *gormCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, p := range snapshotGormPools() {
+		stats := p.db.Stats()
+		labels := []string{p.name, p.role}
+		ch <- prometheus.MustNewConstMetric(c.maxOpenConnections, prometheus.GaugeValue, float64(stats.MaxOpenConnections), labels...)
+		ch <- prometheus.MustNewConstMetric(c.openConnections, prometheus.GaugeValue, float64(stats.OpenConnections), labels...)
+		ch <- prometheus.MustNewConstMetric(c.inUseConnections, prometheus.GaugeValue, float64(stats.InUse), labels...)
+		ch <- prometheus.MustNewConstMetric(c.idleConnections, prometheus.GaugeValue, float64(stats.Idle), labels...)
+		ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount), labels...)
+		ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds(), labels...)
+		ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed), labels...)
+		ch <- prometheus.MustNewConstMetric(c.maxIdleTimeClosed, prometheus.CounterValue, float64(stats.MaxIdleTimeClosed), labels...)
+		ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed), labels...)
+	}
+}
@@ -0,0 +1,102 @@
+package vbasedata
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGormMetricsAndHealth(t *testing.T) {
+	_, closeFn, err := NewGorm(&GormConfig{Name: "metrics", DBPath: filepath.Join(t.TempDir(), "metrics.db")}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := GormStats()["metrics"]; !ok {
+		t.Fatal("pool not registered")
+	}
+	if err := GormHealth(context.Background(), 0); err != nil {
+		t.Fatalf("health: %v", err)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewGormCollector())
+	expected := `
+# HELP go_sql_max_open_connections Maximum number of open connections to the database.
+# TYPE go_sql_max_open_connections gauge
+go_sql_max_open_connections{db_name="metrics",role="primary"} 10
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "go_sql_max_open_connections"); err != nil {
+		t.Fatal(err)
+	}
+
+	closeFn()
+	if _, ok := GormStats()["metrics"]; ok {
+		t.Fatal("pool not unregistered after close")
+	}
+}
+
+func TestGormPool_DuplicateName(t *testing.T) {
+	dir := t.TempDir()
+	_, close1, err := NewGorm(&GormConfig{Name: "dup", DBPath: filepath.Join(dir, "a.db")}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, close2, err := NewGorm(&GormConfig{Name: "dup", DBPath: filepath.Join(dir, "b.db")}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close2()
+
+	stats := GormStats()
+	if _, ok := stats["dup"]; !ok {
+		t.Fatal("first pool overwritten")
+	}
+	if _, ok := stats["dup#2"]; !ok {
+		t.Fatalf("second pool not registered: %v", stats)
+	}
+	if names := DefaultHealth.Names(); !slices.Contains(names, "db:dup") || !slices.Contains(names, "db:dup#2") {
+		t.Fatalf("health checks = %v", names)
+	}
+
+	close1()
+	if _, ok := GormStats()["dup#2"]; !ok {
+		t.Fatal("closing the first pool removed the second")
+	}
+}
+
+func TestGormPool_Reader(t *testing.T) {
+	_, closeFn, err := NewGorm(&GormConfig{
+		Name:   "split",
+		DBPath: filepath.Join(t.TempDir(), "split.db"),
+		SQLite: &SQLiteConfig{JournalMode: "WAL", SplitPool: true, ReaderConns: 3},
+	}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := GormStats()["split/reader1"]; !ok {
+		t.Fatalf("reader pool not registered: %v", GormStats())
+	}
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewGormCollector())
+	expected := `
+# HELP go_sql_max_open_connections Maximum number of open connections to the database.
+# TYPE go_sql_max_open_connections gauge
+go_sql_max_open_connections{db_name="split",role="primary"} 1
+go_sql_max_open_connections{db_name="split",role="reader"} 3
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "go_sql_max_open_connections"); err != nil {
+		t.Fatal(err)
+	}
+
+	closeFn()
+	if _, ok := GormStats()["split/reader1"]; ok {
+		t.Fatal("reader pool not unregistered after close")
+	}
+}
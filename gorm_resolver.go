@@ -0,0 +1,49 @@
+package vbasedata
+
+import (
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+var (
+	replicaPoliciesMu sync.RWMutex
+	replicaPolicies   = map[string]func() dbresolver.Policy{
+		"random": func() dbresolver.Policy {
+			return dbresolver.RandomPolicy{}
+		},
+		"round_robin": dbresolver.StrictRoundRobinPolicy,
+	}
+)
+
+// RegisterReplicaPolicy 注册自定义副本负载均衡策略，GormConfig.Policy中按名称引用
+func RegisterReplicaPolicy(name string, f func() dbresolver.Policy) {
+	replicaPoliciesMu.Lock()
+	defer replicaPoliciesMu.Unlock()
+	replicaPolicies[name] = f
+}
+
+func newReplicaPolicy(name string) (dbresolver.Policy, error) {
+	if name == "" {
+		name = "random"
+	}
+	replicaPoliciesMu.RLock()
+	f, ok := replicaPolicies[name]
+	replicaPoliciesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("未知的副本负载均衡策略:%v", name)
+	}
+	return f(), nil
+}
+
+// UsePrimary 强制本次调用走主库，用于写后立即读等场景
+func UsePrimary(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Write)
+}
+
+// UseReplica 强制本次调用走副本
+func UseReplica(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Read)
+}
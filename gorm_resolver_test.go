@@ -0,0 +1,133 @@
+package vbasedata
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// openResolverTestDB 打开主库和副本，每个库的node表只有一行自己的名称，用于判断查询落在哪个库
+func openResolverTestDB(t *testing.T, policy string, replicas ...string) *gorm.DB {
+	t.Helper()
+	dir := t.TempDir()
+	open := func(name string) gorm.Dialector {
+		path := filepath.Join(dir, name+".db")
+		db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Exec("CREATE TABLE node (name TEXT)").Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Exec("INSERT INTO node (name) VALUES (?)", name).Error; err != nil {
+			t.Fatal(err)
+		}
+		sqlDB, _ := db.DB()
+		_ = sqlDB.Close()
+		return sqlite.Open(path)
+	}
+
+	db, err := gorm.Open(open("primary"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dialectors []gorm.Dialector
+	for _, name := range replicas {
+		dialectors = append(dialectors, open(name))
+	}
+	p, err := newReplicaPolicy(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := dbresolver.Register(dbresolver.Config{Replicas: dialectors, Policy: p})
+	if err := db.Use(resolver); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = closeGorm(db, resolver) })
+	return db
+}
+
+func nodeName(t *testing.T, db *gorm.DB) string {
+	t.Helper()
+	var names []string
+	if err := db.Table("node").Pluck("name", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+func TestResolver_ReadRouting(t *testing.T) {
+	db := openResolverTestDB(t, "", "replica1")
+
+	if got := nodeName(t, db); got != "replica1" {
+		t.Fatalf("read = %q, want replica1", got)
+	}
+	if got := nodeName(t, UsePrimary(db)); got != "primary" {
+		t.Fatalf("UsePrimary read = %q", got)
+	}
+	if got := nodeName(t, UseReplica(db)); got != "replica1" {
+		t.Fatalf("UseReplica read = %q", got)
+	}
+
+	// 写和事务走主库
+	if err := db.Exec("INSERT INTO node (name) VALUES ('written')").Error; err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	if err := UsePrimary(db).Table("node").Count(&n).Error; err != nil || n != 2 {
+		t.Fatalf("primary count = %v, %v", n, err)
+	}
+	if err := db.Table("node").Count(&n).Error; err != nil || n != 1 {
+		t.Fatalf("replica count = %v, %v", n, err)
+	}
+	_ = db.Transaction(func(tx *gorm.DB) error {
+		if got := nodeName(t, tx); got != "primary" {
+			t.Fatalf("read in tx = %q", got)
+		}
+		return nil
+	})
+}
+
+func TestResolver_Policy(t *testing.T) {
+	db := openResolverTestDB(t, "round_robin", "replica1", "replica2")
+	seen := map[string]int{}
+	var prev string
+	for i := 0; i < 6; i++ {
+		got := nodeName(t, db)
+		if got == prev {
+			t.Fatalf("round_robin read %q twice in a row", got)
+		}
+		prev = got
+		seen[got]++
+	}
+	if seen["replica1"] != 3 || seen["replica2"] != 3 {
+		t.Fatalf("round_robin = %v", seen)
+	}
+
+	RegisterReplicaPolicy("last", func() dbresolver.Policy {
+		return dbresolver.PolicyFunc(func(pools []gorm.ConnPool) gorm.ConnPool {
+			return pools[len(pools)-1]
+		})
+	})
+	t.Cleanup(func() {
+		replicaPoliciesMu.Lock()
+		delete(replicaPolicies, "last")
+		replicaPoliciesMu.Unlock()
+	})
+	db = openResolverTestDB(t, "last", "replica1", "replica2")
+	for i := 0; i < 3; i++ {
+		if got := nodeName(t, db); got != "replica2" {
+			t.Fatalf("custom policy read = %q", got)
+		}
+	}
+
+	if _, err := newReplicaPolicy("nope"); err == nil {
+		t.Fatal("unknown policy should fail")
+	}
+}
@@ -0,0 +1,61 @@
+package vbasedata
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewGorm_SQLite_SplitPool(t *testing.T) {
+	db, closeFn, err := NewGorm(&GormConfig{
+		Name:   "sqlite",
+		DBPath: filepath.Join(t.TempDir(), "split.db"),
+		SQLite: &SQLiteConfig{
+			JournalMode: "WAL",
+			BusyTimeout: 3000,
+			Synchronous: "NORMAL",
+			ForeignKeys: true,
+			SplitPool:   true,
+		},
+	}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFn()
+
+	var mode string
+	if err := db.Raw("PRAGMA journal_mode").Scan(&mode).Error; err != nil || !strings.EqualFold(mode, "wal") {
+		t.Fatalf("journal_mode = %q, %v", mode, err)
+	}
+	if err := db.Exec("CREATE TABLE counter (id INTEGER PRIMARY KEY AUTOINCREMENT, n INTEGER)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			errs <- db.Exec("INSERT INTO counter (n) VALUES (?)", i).Error
+		}(i)
+		go func() {
+			defer wg.Done()
+			var n int64
+			errs <- db.Table("counter").Count(&n).Error
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var n int64
+	if err := UsePrimary(db).Table("counter").Count(&n).Error; err != nil || n != 20 {
+		t.Fatalf("count = %v, %v", n, err)
+	}
+}
@@ -0,0 +1,305 @@
+package vbasedata
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	gormQueryStatsName     = "vbasedata:query_stats"
+	gormQueryStatsStartKey = "vbasedata:query_stats:start"
+
+	defaultQueryStatsFingerprints = 1000
+	defaultQueryStatsSamples      = 512
+	defaultQueryStatsSlowQueries  = 100
+)
+
+var (
+	sqlWhitespaceRe   = regexp.MustCompile(`\s+`)
+	sqlValueListRe    = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	sqlRepeatedRowsRe = regexp.MustCompile(`\(\?\)(?:\s*,\s*\(\?\))+`)
+)
+
+// fingerprintSQL 归一化语句，去掉字面量、合并IN列表和多行VALUES，相同结构的语句得到相同的指纹
+func fingerprintSQL(sql string) string {
+	sql = sanitizeSQL(sql)
+	sql = strings.ToLower(strings.TrimSpace(sqlWhitespaceRe.ReplaceAllString(sql, " ")))
+	sql = sqlValueListRe.ReplaceAllString(sql, "(?)")
+	return sqlRepeatedRowsRe.ReplaceAllString(sql, "(?)")
+}
+
+// QueryStat 单个SQL指纹的统计
+type QueryStat struct {
+	Fingerprint string        `json:"fingerprint"`
+	Count       int64         `json:"count"`
+	Errors      int64         `json:"errors"`
+	Total       time.Duration `json:"total"`
+	Max         time.Duration `json:"max"`
+	P50         time.Duration `json:"p50"` // 基于最近的采样计算
+	P99         time.Duration `json:"p99"` // 基于最近的采样计算
+}
+
+// SlowQuery 一次慢查询记录
+type SlowQuery struct {
+	Fingerprint string        `json:"fingerprint"`
+	SQL         string        `json:"sql"` // 去掉字面量后的语句
+	Duration    time.Duration `json:"duration"`
+	Time        time.Time     `json:"time"`
+	Error       string        `json:"error,omitempty"` // 错误分类和驱动错误码，如duplicate key (mysql 1062)，不含原始错误信息
+}
+
+// QueryStatsOrder Top报表的排序方式
+type QueryStatsOrder string
+
+const (
+	QueryStatsByCount  QueryStatsOrder = "count"
+	QueryStatsByTotal  QueryStatsOrder = "total"
+	QueryStatsByP99    QueryStatsOrder = "p99"
+	QueryStatsByErrors QueryStatsOrder = "errors"
+)
+
+type queryStatEntry struct {
+	count   int64
+	errors  int64
+	total   time.Duration
+	max     time.Duration
+	samples []time.Duration // 环形缓冲
+	next    int
+}
+
+// QueryStats 按指纹聚合的语句统计插件，数据只保存在内存中
+type QueryStats struct {
+	mu              sync.Mutex
+	entries         map[string]*queryStatEntry
+	slow            []SlowQuery
+	slowThreshold   time.Duration
+	maxFingerprints int
+	maxSamples      int
+	maxSlowQueries  int
+}
+
+// NewQueryStats 创建语句统计插件，slowThreshold大于0时记录最近的慢查询
+func NewQueryStats(slowThreshold time.Duration) *QueryStats {
+	return &QueryStats{
+		entries:         make(map[string]*queryStatEntry),
+		slowThreshold:   slowThreshold,
+		maxFingerprints: defaultQueryStatsFingerprints,
+		maxSamples:      defaultQueryStatsSamples,
+		maxSlowQueries:  defaultQueryStatsSlowQueries,
+	}
+}
+
+// GetQueryStats 获取db上启用的语句统计插件，未启用时返回nil
+func GetQueryStats(db *gorm.DB) *QueryStats {
+	if p, ok := db.Config.Plugins[gormQueryStatsName]; ok {
+		if qs, ok := p.(*QueryStats); ok {
+			return qs
+		}
+	}
+	return nil
+}
+
+// Name implements gorm.Plugin.
+func (s *QueryStats) Name() string {
+	return gormQueryStatsName
+}
+
+// Initialize implements gorm.Plugin.
+func (s *QueryStats) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		name   string
+		before func(string, func(*gorm.DB)) error
+		after  func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, h := range hooks {
+		if err := h.before(gormQueryStatsName+":before_"+h.name, s.before); err != nil {
+			return err
+		}
+		if err := h.after(gormQueryStatsName+":after_"+h.name, s.after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *QueryStats) before(db *gorm.DB) {
+	db.InstanceSet(gormQueryStatsStartKey, time.Now())
+}
+
+func (s *QueryStats) after(db *gorm.DB) {
+	v, ok := db.InstanceGet(gormQueryStatsStartKey)
+	if !ok {
+		return
+	}
+	start, ok := v.(time.Time)
+	if !ok {
+		return
+	}
+	sql := db.Statement.SQL.String()
+	if sql == "" {
+		return
+	}
+	failed := db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound)
+	var errMsg string
+	if failed {
+		errMsg = queryErrorClass(db.Error)
+	}
+	s.record(sql, time.Since(start), failed, errMsg)
+}
+
+// queryErrorClass 返回错误的分类和驱动错误码，原始错误信息可能带有参数值，不保留
+func queryErrorClass(err error) string {
+	class := "error"
+	switch {
+	case IsNotFound(err):
+		class = ErrNotFound.Error()
+	case IsDuplicateKey(err):
+		class = ErrDuplicateKey.Error()
+	case IsForeignKeyViolation(err):
+		class = ErrForeignKeyViolation.Error()
+	case IsDeadlock(err):
+		class = ErrDeadlock.Error()
+	case IsTimeout(err):
+		class = ErrTimeout.Error()
+	case IsConnectionLost(err):
+		class = ErrConnectionLost.Error()
+	}
+	if n, ok := mysqlErrNumber(err); ok {
+		return fmt.Sprintf("%s (mysql %d)", class, n)
+	}
+	if code, ok := pgErrCode(err); ok {
+		return fmt.Sprintf("%s (pg %s)", class, code)
+	}
+	if code, ok := sqliteErrCode(err); ok {
+		return fmt.Sprintf("%s (sqlite %d)", class, code)
+	}
+	return class
+}
+
+func (s *QueryStats) record(sql string, d time.Duration, failed bool, errMsg string) {
+	fp := fingerprintSQL(sql)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[fp]
+	if !ok {
+		if len(s.entries) >= s.maxFingerprints {
+			// 指纹数量达到上限时不再新增，避免拼接SQL导致内存无限增长
+			return
+		}
+		e = &queryStatEntry{}
+		s.entries[fp] = e
+	}
+	e.count++
+	e.total += d
+	if d > e.max {
+		e.max = d
+	}
+	if failed {
+		e.errors++
+	}
+	if len(e.samples) < s.maxSamples {
+		e.samples = append(e.samples, d)
+	} else {
+		e.samples[e.next] = d
+		e.next = (e.next + 1) % s.maxSamples
+	}
+
+	if s.slowThreshold > 0 && d >= s.slowThreshold {
+		s.slow = append(s.slow, SlowQuery{
+			Fingerprint: fp,
+			SQL:         sanitizeSQL(sql),
+			Duration:    d,
+			Time:        time.Now(),
+			Error:       errMsg,
+		})
+		if len(s.slow) > s.maxSlowQueries {
+			s.slow = s.slow[len(s.slow)-s.maxSlowQueries:]
+		}
+	}
+}
+
+// Top 返回按order排序的前n个指纹统计，n小于等于0时返回全部
+func (s *QueryStats) Top(n int, order QueryStatsOrder) []QueryStat {
+	s.mu.Lock()
+	stats := make([]QueryStat, 0, len(s.entries))
+	for fp, e := range s.entries {
+		samples := append([]time.Duration(nil), e.samples...)
+		sort.Slice(samples, func(i, j int) bool {
+			return samples[i] < samples[j]
+		})
+		stats = append(stats, QueryStat{
+			Fingerprint: fp,
+			Count:       e.count,
+			Errors:      e.errors,
+			Total:       e.total,
+			Max:         e.max,
+			P50:         percentile(samples, 0.50),
+			P99:         percentile(samples, 0.99),
+		})
+	}
+	s.mu.Unlock()
+
+	less := func(a, b QueryStat) bool {
+		switch order {
+		case QueryStatsByTotal:
+			return a.Total > b.Total
+		case QueryStatsByP99:
+			return a.P99 > b.P99
+		case QueryStatsByErrors:
+			return a.Errors > b.Errors
+		default:
+			return a.Count > b.Count
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if less(stats[i], stats[j]) != less(stats[j], stats[i]) {
+			return less(stats[i], stats[j])
+		}
+		return stats[i].Fingerprint < stats[j].Fingerprint
+	})
+	if n > 0 && len(stats) > n {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+// SlowQueries 返回最近的慢查询，按时间先后排列
+func (s *QueryStats) SlowQueries() []SlowQuery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SlowQuery(nil), s.slow...)
+}
+
+// Reset 清空所有统计
+func (s *QueryStats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = make(map[string]*queryStatEntry)
+	s.slow = nil
+}
+
+// percentile 计算已排序采样的分位数
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted)-1) * p)
+	return sorted[idx]
+}
@@ -0,0 +1,70 @@
+package vbasedata
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFingerprintSQL(t *testing.T) {
+	cases := map[string]string{
+		"SELECT * FROM user WHERE id = 1":                       "select * from user where id = ?",
+		"SELECT * FROM user WHERE id IN (1, 2, 3)":              "select * from user where id in (?)",
+		"select *  from user where name = 'a''b' and id = $1":   "select * from user where name = ? and id = ?",
+		"INSERT INTO user (name) VALUES (?),(?), (?)":           "insert into user (name) values (?)",
+		"INSERT INTO user (id, name) VALUES (1, 'a'), (2, 'b')": "insert into user (id, name) values (?)",
+	}
+	for in, want := range cases {
+		if got := fingerprintSQL(in); got != want {
+			t.Errorf("fingerprintSQL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestQueryStats(t *testing.T) {
+	db, closeFn, err := NewGorm(&GormConfig{
+		Name:       "stats",
+		DBPath:     filepath.Join(t.TempDir(), "stats.db"),
+		QueryStats: true,
+		Logconfig:  &Logconfig{SlowThreshold: 1},
+	}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFn()
+
+	qs := GetQueryStats(db)
+	if qs == nil {
+		t.Fatal("query stats plugin not enabled")
+	}
+	db.Exec("CREATE TABLE user (id INTEGER PRIMARY KEY, name TEXT)")
+	for i := 0; i < 5; i++ {
+		db.Exec("INSERT INTO user (id, name) VALUES (?, ?)", i, "n")
+	}
+	db.Exec("INSERT INTO missing (id) VALUES (1)")
+
+	top := qs.Top(1, QueryStatsByCount)
+	if len(top) != 1 || top[0].Count != 5 || top[0].Fingerprint != "insert into user (id, name) values (?)" {
+		t.Fatalf("unexpected top %+v", top)
+	}
+	errs := qs.Top(1, QueryStatsByErrors)
+	if errs[0].Errors != 1 {
+		t.Fatalf("unexpected errors top %+v", errs)
+	}
+	if len(qs.Top(0, QueryStatsByP99)) != 3 {
+		t.Fatal("expected 3 fingerprints")
+	}
+
+	// 慢查询只记录错误分类，不带驱动错误信息中的参数值
+	qs.Reset()
+	qs.slowThreshold = time.Nanosecond
+	db.Exec("CREATE TABLE account (email TEXT UNIQUE)")
+	db.Exec("INSERT INTO account (email) VALUES (?)", "secret@example.com")
+	db.Exec("INSERT INTO account (email) VALUES (?)", "secret@example.com")
+	slow := qs.SlowQueries()
+	last := slow[len(slow)-1]
+	if !strings.HasPrefix(last.Error, ErrDuplicateKey.Error()) || strings.Contains(last.Error, "account") {
+		t.Fatalf("slow query error = %q", last.Error)
+	}
+}
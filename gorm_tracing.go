@@ -0,0 +1,137 @@
+package vbasedata
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	gormTracingName    = "vbasedata:tracing"
+	gormTracingSpanKey = "vbasedata:tracing:span"
+)
+
+var (
+	sqlStringLiteralRe = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'`)
+	sqlNumberLiteralRe = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	sqlPGPlaceholderRe = regexp.MustCompile(`\$\d+`)
+)
+
+// sanitizeSQL 去掉语句中的字符串和数字字面量，避免参数值进入链路数据，pg的$n占位符统一为?
+func sanitizeSQL(sql string) string {
+	sql = sqlPGPlaceholderRe.ReplaceAllString(sql, "?")
+	sql = sqlStringLiteralRe.ReplaceAllString(sql, "?")
+	return sqlNumberLiteralRe.ReplaceAllString(sql, "?")
+}
+
+// GormTracing gorm链路追踪插件，每条语句创建一个span，父span从语句的ctx中获取
+type GormTracing struct {
+	tracer   trace.Tracer
+	dbSystem string
+	dbName   string
+}
+
+// NewGormTracing 创建链路追踪插件，tp为空时使用全局TracerProvider
+func NewGormTracing(c *GormConfig, tp trace.TracerProvider) *GormTracing {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	p := &GormTracing{
+		tracer: tp.Tracer("github.com/aveyuan/vbasedata"),
+	}
+	if c != nil {
+		switch c.Type {
+		case "mysql":
+			p.dbSystem = "mysql"
+			p.dbName = c.DBName
+		case "pg":
+			p.dbSystem = "postgresql"
+			p.dbName = c.DBName
+		default:
+			p.dbSystem = "sqlite"
+			p.dbName = c.DBPath
+		}
+	}
+	return p
+}
+
+// Name implements gorm.Plugin.
+func (p *GormTracing) Name() string {
+	return gormTracingName
+}
+
+// Initialize implements gorm.Plugin.
+func (p *GormTracing) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		name   string
+		before func(string, func(*gorm.DB)) error
+		after  func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, h := range hooks {
+		if err := h.before(gormTracingName+":before_"+h.name, p.before(h.name)); err != nil {
+			return err
+		}
+		if err := h.after(gormTracingName+":after_"+h.name, p.after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *GormTracing) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement == nil || db.Statement.Context == nil {
+			return
+		}
+		name := "gorm." + operation
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+		_, span := p.tracer.Start(db.Statement.Context, name, trace.WithSpanKind(trace.SpanKindClient))
+		db.InstanceSet(gormTracingSpanKey, span)
+	}
+}
+
+func (p *GormTracing) after(db *gorm.DB) {
+	v, ok := db.InstanceGet(gormTracingSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := v.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", p.dbSystem),
+		attribute.String("db.name", p.dbName),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	}
+	if stmt := strings.TrimSpace(db.Statement.SQL.String()); stmt != "" {
+		attrs = append(attrs, attribute.String("db.statement", sanitizeSQL(stmt)))
+	}
+	if db.Statement.Table != "" {
+		attrs = append(attrs, attribute.String("db.sql.table", db.Statement.Table))
+	}
+	span.SetAttributes(attrs...)
+
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
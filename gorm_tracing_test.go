@@ -0,0 +1,55 @@
+package vbasedata
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestGormTracing(t *testing.T) {
+	c := &GormConfig{Name: "tracing", DBPath: filepath.Join(t.TempDir(), "tracing.db")}
+	db, closeFn, err := NewGorm(c, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFn()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	if err := db.Use(NewGormTracing(c, tp)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	db.WithContext(ctx).Exec("CREATE TABLE user (id INTEGER PRIMARY KEY, name TEXT)")
+	db.WithContext(ctx).Exec("INSERT INTO user (id, name) VALUES (1, 'secret')")
+	db.WithContext(ctx).Exec("INSERT INTO missing (id) VALUES (1)")
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 4 {
+		t.Fatalf("got %d spans, want 4", len(spans))
+	}
+	insert := spans[1]
+	if insert.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatal("span parent not taken from ctx")
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range insert.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	if attrs["db.system"].AsString() != "sqlite" || attrs["db.rows_affected"].AsInt64() != 1 {
+		t.Fatalf("unexpected attributes %v", insert.Attributes)
+	}
+	if got := attrs["db.statement"].AsString(); got != "INSERT INTO user (id, name) VALUES (?, ?)" {
+		t.Fatalf("statement not sanitized: %q", got)
+	}
+	if spans[2].Status.Code != codes.Error {
+		t.Fatal("expected error status on failing statement")
+	}
+}
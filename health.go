@@ -0,0 +1,212 @@
+package vbasedata
+
+import (
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/transport/http"
+)
+
+// HealthStatus 健康状态
+type HealthStatus string
+
+const (
+	HealthUp       HealthStatus = "up"
+	HealthDegraded HealthStatus = "degraded" // 非关键依赖异常，仍可对外服务
+	HealthDown     HealthStatus = "down"
+)
+
+const (
+	defaultHealthTimeout  = 2 * time.Second
+	defaultHealthCacheTTL = 5 * time.Second
+)
+
+// HealthCheck 单项健康检查
+type HealthCheck struct {
+	Name     string
+	Critical bool                            // 关键依赖，失败时整体为down，否则为degraded
+	Timeout  time.Duration                   // 单次检查超时，默认2s
+	Check    func(ctx context.Context) error // 返回nil为正常
+}
+
+// HealthResult 单项检查结果
+type HealthResult struct {
+	Status   HealthStatus `json:"status"`
+	Critical bool         `json:"critical"`
+	Duration string       `json:"duration"`
+	Error    string       `json:"error,omitempty"`
+	Time     time.Time    `json:"time"`
+}
+
+// HealthReport 整体检查结果
+type HealthReport struct {
+	Status HealthStatus             `json:"status"`
+	Checks map[string]*HealthResult `json:"checks,omitempty"`
+}
+
+type healthEntry struct {
+	check  HealthCheck
+	mu     sync.Mutex
+	result *HealthResult
+}
+
+// HealthRegistry 健康检查注册表，检查结果在cacheTTL内复用，避免探针频繁访问依赖
+type HealthRegistry struct {
+	mu       sync.RWMutex
+	entries  map[string]*healthEntry
+	cacheTTL time.Duration
+}
+
+// DefaultHealth NewGorm/NewRedis/NewPond自动注册检查的注册表，NewEmail在设置health_check时注册
+var DefaultHealth = NewHealthRegistry(defaultHealthCacheTTL)
+
+// NewHealthRegistry 创建健康检查注册表，cacheTTL为0时每次都重新检查
+func NewHealthRegistry(cacheTTL time.Duration) *HealthRegistry {
+	return &HealthRegistry{
+		entries:  make(map[string]*healthEntry),
+		cacheTTL: cacheTTL,
+	}
+}
+
+// Register 注册检查，同名时覆盖，返回的函数用于移除本次注册的检查
+func (r *HealthRegistry) Register(c HealthCheck) func() {
+	if c.Timeout <= 0 {
+		c.Timeout = defaultHealthTimeout
+	}
+	e := &healthEntry{check: c}
+	r.mu.Lock()
+	r.entries[c.Name] = e
+	r.mu.Unlock()
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.entries[c.Name] == e {
+			delete(r.entries, c.Name)
+		}
+	}
+}
+
+// Unregister 移除检查
+func (r *HealthRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, name)
+}
+
+// Names 返回所有检查名称
+func (r *HealthRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.entries))
+	for name := range r.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Liveness 进程存活检查，不访问任何依赖
+func (r *HealthRegistry) Liveness() *HealthReport {
+	return &HealthReport{Status: HealthUp}
+}
+
+// Readiness 并发执行所有检查并汇总，关键依赖失败为down，非关键依赖失败为degraded
+func (r *HealthRegistry) Readiness(ctx context.Context) *HealthReport {
+	r.mu.RLock()
+	entries := make(map[string]*healthEntry, len(r.entries))
+	for name, e := range r.entries {
+		entries[name] = e
+	}
+	r.mu.RUnlock()
+
+	report := &HealthReport{
+		Status: HealthUp,
+		Checks: make(map[string]*HealthResult, len(entries)),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, e := range entries {
+		wg.Add(1)
+		go func(name string, e *healthEntry) {
+			defer wg.Done()
+			res := r.run(ctx, e)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = res
+			if res.Status == HealthUp {
+				return
+			}
+			if res.Critical {
+				report.Status = HealthDown
+			} else if report.Status == HealthUp {
+				report.Status = HealthDegraded
+			}
+		}(name, e)
+	}
+	wg.Wait()
+	return report
+}
+
+// run 执行单项检查，缓存未过期时直接返回上次结果
+func (r *HealthRegistry) run(ctx context.Context, e *healthEntry) *HealthResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.result != nil && time.Since(e.result.Time) < r.cacheTTL {
+		return e.result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.check.Timeout)
+	defer cancel()
+	start := time.Now()
+	err := e.check.Check(ctx)
+	res := &HealthResult{
+		Status:   HealthUp,
+		Critical: e.check.Critical,
+		Duration: time.Since(start).String(),
+		Time:     start,
+	}
+	if err != nil {
+		res.Error = err.Error()
+		res.Status = HealthDegraded
+		if e.check.Critical {
+			res.Status = HealthDown
+		}
+	}
+	e.result = res
+	return res
+}
+
+// Handler 返回健康检查的http处理器，path为前缀：path/live为存活检查，path和path/ready为就绪检查。
+// down时返回503，up和degraded返回200
+func (r *HealthRegistry) Handler(path string) nethttp.Handler {
+	path = strings.TrimRight(path, "/")
+	return nethttp.HandlerFunc(func(w nethttp.ResponseWriter, req *nethttp.Request) {
+		var report *HealthReport
+		switch strings.TrimRight(req.URL.Path, "/") {
+		case path + "/live":
+			report = r.Liveness()
+		case path, path + "/ready":
+			report = r.Readiness(req.Context())
+		default:
+			nethttp.NotFound(w, req)
+			return
+		}
+		code := nethttp.StatusOK
+		if report.Status == HealthDown {
+			code = nethttp.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
+// RegisterHealthHandler 将注册表挂载到kratos http服务的path下
+func RegisterHealthHandler(srv *http.Server, path string, r *HealthRegistry) {
+	srv.HandlePrefix(path, r.Handler(path))
+}
@@ -0,0 +1,110 @@
+package vbasedata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alitto/pond"
+)
+
+func TestHealthRegistry(t *testing.T) {
+	r := NewHealthRegistry(time.Minute)
+	calls := 0
+	r.Register(HealthCheck{Name: "db", Critical: true, Check: func(ctx context.Context) error {
+		calls++
+		return nil
+	}})
+	r.Register(HealthCheck{Name: "smtp", Timeout: 10 * time.Millisecond, Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+
+	h := r.Handler("/health")
+	get := func(path string) (int, HealthReport) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, path, nil))
+		var report HealthReport
+		_ = json.Unmarshal(rec.Body.Bytes(), &report)
+		return rec.Code, report
+	}
+
+	code, report := get("/health/ready")
+	if code != nethttp.StatusOK || report.Status != HealthDegraded || report.Checks["smtp"].Error == "" {
+		t.Fatalf("ready = %d %+v", code, report)
+	}
+	// 缓存期内不重复检查
+	get("/health")
+	if calls != 1 {
+		t.Fatalf("calls = %d", calls)
+	}
+
+	unregister := r.Register(HealthCheck{Name: "redis", Critical: true, Check: func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}})
+	if code, report := get("/health"); code != nethttp.StatusServiceUnavailable || report.Status != HealthDown {
+		t.Fatalf("ready = %d %+v", code, report)
+	}
+	if code, report := get("/health/live"); code != nethttp.StatusOK || report.Status != HealthUp {
+		t.Fatalf("live = %d %+v", code, report)
+	}
+	unregister()
+	if code, _ := get("/health"); code != nethttp.StatusOK {
+		t.Fatalf("code after unregister = %d", code)
+	}
+}
+
+func TestHealthRegisteredByConstructors(t *testing.T) {
+	_, cleanup, err := NewGorm(&GormConfig{Name: "health_test", DBPath: filepath.Join(t.TempDir(), "h.db")}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPond(&PondConfig{Name: "health_test"}, newTestLogger())
+	p2 := NewPond(&PondConfig{}, newTestLogger())
+	p3 := NewPond(&PondConfig{}, newTestLogger())
+	e := NewEmail(&EmailConfig{Host: "127.0.0.1", Port: "1"})
+	he := NewEmail(&EmailConfig{Host: "127.0.0.1", Port: "2", HealthCheck: true})
+
+	report := DefaultHealth.Readiness(context.Background())
+	if report.Checks["db:health_test"] == nil || report.Checks["db:health_test"].Status != HealthUp {
+		t.Fatalf("db check = %+v", report.Checks["db:health_test"])
+	}
+	if report.Checks["pond:health_test"] == nil || report.Checks["pond:health_test"].Status != HealthUp {
+		t.Fatalf("pond check = %+v", report.Checks["pond:health_test"])
+	}
+	var unnamed int
+	for name := range report.Checks {
+		if strings.HasPrefix(name, "pond#") {
+			unnamed++
+		}
+	}
+	if unnamed < 2 {
+		t.Fatalf("unnamed pond checks = %d, want distinct names", unnamed)
+	}
+	if report.Checks["smtp:127.0.0.1:1"] != nil {
+		t.Fatal("smtp check should be opt-in")
+	}
+	if report.Checks["smtp:127.0.0.1:2"] == nil {
+		t.Fatal("smtp check not registered")
+	}
+
+	cleanup()
+	p.Stop()
+	p2.Stop()
+	p3.Stop()
+	e.Close()
+	he.Close()
+	he.Close()
+	(&Pond{pond: pond.New(1, 0)}).Stop()
+	for _, name := range DefaultHealth.Names() {
+		if name == "db:health_test" || strings.HasPrefix(name, "pond") || strings.HasPrefix(name, "smtp:") {
+			t.Fatalf("%v still registered", name)
+		}
+	}
+}
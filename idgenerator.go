@@ -4,6 +4,11 @@ import (
 	"github.com/yitter/idgenerator-go/idgen"
 )
 
+// IdgenConfig id生成器配置
+type IdgenConfig struct {
+	WorkerId uint16 `yaml:"worker_id" json:"worker_id"` // 节点编号，0-15，多节点部署时需各不相同
+}
+
 type Idgenerator struct {
 }
 
@@ -2,12 +2,15 @@ package vbasedata
 
 import (
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/golang-lru/v2/expirable"
 )
 
+// LruCache 进程内验证码存储，读取并清除(clear)在锁内完成，并发校验同一个id时只有一个请求能取到答案
 type LruCache struct {
+	mu  sync.Mutex
 	lru *expirable.LRU[string, string]
 }
 
@@ -18,11 +21,15 @@ func NewLruCache(len int, exp time.Duration) *LruCache {
 }
 
 func (s *LruCache) Set(id string, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.lru.Add(id, value)
 	return nil
 }
 
 func (s *LruCache) Get(id string, clear bool) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	v, ok := s.lru.Get(id)
 	if ok {
 		if clear {
@@ -34,6 +41,8 @@ func (s *LruCache) Get(id string, clear bool) string {
 }
 
 func (s *LruCache) Incr(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	v, ok := s.lru.Get(id)
 	if ok {
 		i, err := strconv.Atoi(v)
@@ -41,7 +50,7 @@ func (s *LruCache) Incr(id string) error {
 			s.lru.Add(id, "1")
 			return nil
 		}
-		s.lru.Add(id, strconv.Itoa(i))
+		s.lru.Add(id, strconv.Itoa(i+1))
 		return nil
 	}
 	s.lru.Add(id, "1")
@@ -49,6 +58,8 @@ func (s *LruCache) Incr(id string) error {
 }
 
 func (s *LruCache) Verify(id, answer string, clear bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	v, ok := s.lru.Get(id)
 	if ok {
 		if clear {
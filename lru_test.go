@@ -3,6 +3,8 @@ package vbasedata
 import (
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -21,3 +23,35 @@ func TestLruCache(t *testing.T) {
 		log.Print(lru.Get(fmt.Sprintf("%v", i-1)))
 	}
 }
+
+func TestLruCache_Incr(t *testing.T) {
+	c := NewLruCache(10, time.Minute)
+	for i := 0; i < 3; i++ {
+		_ = c.Incr("n")
+	}
+	if v := c.Get("n", false); v != "3" {
+		t.Fatalf("incr = %q", v)
+	}
+}
+
+func TestLruCache_GetClearConcurrent(t *testing.T) {
+	c := NewLruCache(10, time.Minute)
+	_ = c.Set("id", "42")
+	var (
+		wg    sync.WaitGroup
+		taken atomic.Int32
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if c.Get("id", true) != "" {
+				taken.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if taken.Load() != 1 {
+		t.Fatalf("answer taken %d times", taken.Load())
+	}
+}
@@ -0,0 +1,361 @@
+package vbasedata
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"gorm.io/gorm"
+)
+
+const (
+	migrationTable       = "schema_migrations"
+	migrationLockName    = "vbasedata_schema_migrations"
+	migrationLockTimeout = 60 * time.Second
+)
+
+// 迁移文件命名: 0001_create_user.up.sql / 0001_create_user.down.sql
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration 单个版本的迁移脚本
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrator 基于SQL文件的版本化迁移，所有语句都在主库执行。
+// 每个版本的脚本和版本记录在同一事务中执行；mysql的DDL会隐式提交，脚本中途失败时已执行的DDL不会回滚且不会记录版本，需要手工处理后重试
+type Migrator struct {
+	db     *gorm.DB
+	c      *GormConfig
+	fsys   fs.FS
+	logger *log.Helper
+}
+
+// NewMigrator 创建迁移器，fsys根目录下存放编号的up/down SQL文件
+func NewMigrator(db *gorm.DB, c *GormConfig, fsys fs.FS, logger *log.Helper) *Migrator {
+	return &Migrator{
+		db:     db,
+		c:      c,
+		fsys:   fsys,
+		logger: logger,
+	}
+}
+
+// Migrations 读取并按版本排序所有迁移脚本
+func (m *Migrator) Migrations() ([]*Migration, error) {
+	entries, err := fs.ReadDir(m.fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		match := migrationFileRe.FindStringSubmatch(e.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("迁移文件版本号错误:%v", e.Name())
+		}
+		b, err := fs.ReadFile(m.fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mg
+		} else if mg.Name != match[2] {
+			return nil, fmt.Errorf("迁移版本%d存在多个名称:%v,%v", version, mg.Name, match[2])
+		}
+		if match[3] == "up" {
+			mg.Up = string(b)
+		} else {
+			mg.Down = string(b)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if strings.TrimSpace(mg.Up) == "" {
+			return nil, fmt.Errorf("迁移版本%d缺少up脚本", mg.Version)
+		}
+		migrations = append(migrations, mg)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up 执行所有未应用的迁移
+func (m *Migrator) Up(ctx context.Context) error {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return err
+	}
+	return m.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+		for _, mg := range migrations {
+			if applied[mg.Version] {
+				continue
+			}
+			m.logger.Infof("数据库迁移:执行 %d_%s up", mg.Version, mg.Name)
+			if err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.exec(tx, mg.Up); err != nil {
+					return err
+				}
+				return tx.Exec(fmt.Sprintf("INSERT INTO %s (version, name, applied_at) VALUES (?, ?, ?)", migrationTable),
+					mg.Version, mg.Name, time.Now()).Error
+			}); err != nil {
+				return fmt.Errorf("迁移%d_%s执行失败: %w", mg.Version, mg.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Down 回滚最近应用的steps个迁移
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if steps <= 0 {
+		return nil
+	}
+	migrations, err := m.Migrations()
+	if err != nil {
+		return err
+	}
+	byVersion := make(map[int64]*Migration, len(migrations))
+	for _, mg := range migrations {
+		byVersion[mg.Version] = mg
+	}
+	return m.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int64, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i] > versions[j]
+		})
+		if steps < len(versions) {
+			versions = versions[:steps]
+		}
+		for _, v := range versions {
+			mg, ok := byVersion[v]
+			if !ok {
+				return fmt.Errorf("迁移版本%d的脚本不存在，无法回滚", v)
+			}
+			if strings.TrimSpace(mg.Down) == "" {
+				return fmt.Errorf("迁移版本%d缺少down脚本", v)
+			}
+			m.logger.Infof("数据库迁移:执行 %d_%s down", mg.Version, mg.Name)
+			if err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.exec(tx, mg.Down); err != nil {
+					return err
+				}
+				return tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE version = ?", migrationTable), mg.Version).Error
+			}); err != nil {
+				return fmt.Errorf("迁移%d_%s回滚失败: %w", mg.Version, mg.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Version 返回当前已应用的最高版本，未应用任何迁移时返回0
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	conn := m.primary(ctx)
+	if err := m.ensureTable(conn); err != nil {
+		return 0, err
+	}
+	var v sql.NullInt64
+	if err := conn.Raw(fmt.Sprintf("SELECT MAX(version) FROM %s", migrationTable)).Scan(&v).Error; err != nil {
+		return 0, err
+	}
+	return v.Int64, nil
+}
+
+func (m *Migrator) ensureTable(conn *gorm.DB) error {
+	return conn.Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL)",
+		migrationTable,
+	)).Error
+}
+
+func (m *Migrator) applied(conn *gorm.DB) (map[int64]bool, error) {
+	if err := m.ensureTable(conn); err != nil {
+		return nil, err
+	}
+	var versions []int64
+	if err := conn.Raw(fmt.Sprintf("SELECT version FROM %s", migrationTable)).Scan(&versions).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+	return applied, nil
+}
+
+// exec 执行一个脚本，mysql驱动默认不支持多语句，需要拆分执行
+func (m *Migrator) exec(tx *gorm.DB, script string) error {
+	if tx.Dialector.Name() != "mysql" {
+		return tx.Exec(script).Error
+	}
+	for _, stmt := range splitSQLStatements(script) {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// primary 迁移的所有读写都走主库，配置了副本或SQLite读连接池时，原生SELECT默认会被路由到读库
+func (m *Migrator) primary(ctx context.Context) *gorm.DB {
+	return UsePrimary(m.db).WithContext(ctx)
+}
+
+// withLock 在迁移期间持有锁，mysql/pg使用advisory lock，sqlite使用文件锁
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	db := m.primary(ctx)
+	switch db.Dialector.Name() {
+	case "mysql":
+		return m.withSessionLock(ctx, func(c *sql.Conn) error {
+			var got sql.NullInt64
+			if err := c.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLockName, int(migrationLockTimeout.Seconds())).Scan(&got); err != nil {
+				return err
+			}
+			if !got.Valid || got.Int64 != 1 {
+				return errors.New("获取数据库迁移锁超时")
+			}
+			return nil
+		}, func(ctx context.Context, c *sql.Conn) error {
+			_, err := c.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", migrationLockName)
+			return err
+		}, func() error {
+			return fn(db)
+		})
+	case "postgres":
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(migrationLockName))
+		key := int64(h.Sum64())
+		return m.withSessionLock(ctx, func(c *sql.Conn) error {
+			_, err := c.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key)
+			return err
+		}, func(ctx context.Context, c *sql.Conn) error {
+			_, err := c.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", key)
+			return err
+		}, func() error {
+			return fn(db)
+		})
+	default:
+		path := "data.db"
+		if m.c != nil && m.c.DBPath != "" {
+			path = m.c.DBPath
+		}
+		unlock, err := lockFile(ctx, path+".migrate.lock", migrationLockTimeout)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		return fn(db)
+	}
+}
+
+// withSessionLock 在主库的一个专用连接上持有会话级锁，加锁和解锁必须在同一连接上，迁移语句走主库连接池。
+// 解锁失败时丢弃该连接，由数据库在会话结束时释放锁
+func (m *Migrator) withSessionLock(ctx context.Context, lock func(c *sql.Conn) error, unlock func(ctx context.Context, c *sql.Conn) error, fn func() error) error {
+	sqlDB, err := m.db.DB()
+	if err != nil {
+		return err
+	}
+	c, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := lock(c); err != nil {
+		return err
+	}
+	defer func() {
+		if err := unlock(context.WithoutCancel(ctx), c); err != nil {
+			m.logger.Errorf("数据库迁移锁释放失败 %v", err)
+			_ = c.Raw(func(any) error { return driver.ErrBadConn })
+		}
+	}()
+	return fn()
+}
+
+// splitSQLStatements 按分号拆分SQL脚本，忽略引号和注释中的分号
+func splitSQLStatements(script string) []string {
+	var (
+		stmts []string
+		buf   strings.Builder
+		quote rune
+	)
+	runes := []rune(script)
+	flush := func() {
+		if s := strings.TrimSpace(buf.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		buf.Reset()
+	}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if quote != 0 {
+			buf.WriteRune(r)
+			if r == '\\' && quote != '`' && i+1 < len(runes) {
+				i++
+				buf.WriteRune(runes[i])
+			} else if r == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+			buf.WriteRune(r)
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-', r == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			buf.WriteRune('\n')
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/') {
+				i++
+			}
+			i++
+			buf.WriteRune(' ')
+		case r == ';':
+			flush()
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	flush()
+	return stmts
+}
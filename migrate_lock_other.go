@@ -0,0 +1,35 @@
+//go:build !unix
+
+package vbasedata
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+// lockFile 非unix平台使用独占创建锁文件的方式加锁
+func lockFile(ctx context.Context, path string, timeout time.Duration) (func(), error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0o644)
+		if err == nil {
+			return func() {
+				_ = f.Close()
+				_ = os.Remove(path)
+			}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("获取文件锁超时:" + path)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package vbasedata
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMigrator(t *testing.T) {
+	c := &GormConfig{Name: "migrate", DBPath: filepath.Join(t.TempDir(), "migrate.db")}
+	db, closeFn, err := NewGorm(c, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFn()
+
+	fsys := fstest.MapFS{
+		"0001_create_user.up.sql":   {Data: []byte("CREATE TABLE user (id INTEGER PRIMARY KEY, name TEXT);")},
+		"0001_create_user.down.sql": {Data: []byte("DROP TABLE user;")},
+		"0002_add_email.up.sql":     {Data: []byte("ALTER TABLE user ADD COLUMN email TEXT;")},
+		"0002_add_email.down.sql":   {Data: []byte("ALTER TABLE user DROP COLUMN email;")},
+		"README.md":                 {Data: []byte("ignored")},
+	}
+	m := NewMigrator(db, c, fsys, newTestLogger())
+	ctx := context.Background()
+
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if v, err := m.Version(ctx); err != nil || v != 2 {
+		t.Fatalf("version after up = %v, %v", v, err)
+	}
+	// 重复执行无副作用
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("up again: %v", err)
+	}
+	if err := m.Down(ctx, 1); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if v, _ := m.Version(ctx); v != 1 {
+		t.Fatalf("version after down = %v", v)
+	}
+	if err := db.Exec("INSERT INTO user (id, name) VALUES (1, 'a')").Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrator_Replica(t *testing.T) {
+	db := openResolverTestDB(t, "", "replica1")
+	c := &GormConfig{DBPath: filepath.Join(t.TempDir(), "lock.db")}
+	fsys := fstest.MapFS{
+		"0001_create_user.up.sql":   {Data: []byte("CREATE TABLE user (id INTEGER PRIMARY KEY);")},
+		"0001_create_user.down.sql": {Data: []byte("DROP TABLE user;")},
+	}
+	m := NewMigrator(db, c, fsys, newTestLogger())
+	ctx := context.Background()
+
+	// 版本表只在主库，读取不能落到副本
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("up again: %v", err)
+	}
+	if v, err := m.Version(ctx); err != nil || v != 1 {
+		t.Fatalf("version = %v, %v", v, err)
+	}
+	if err := m.Down(ctx, 1); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+}
+
+func TestSplitSQLStatements(t *testing.T) {
+	stmts := splitSQLStatements("CREATE TABLE a (x TEXT DEFAULT ';'); -- c;\n/* b; */INSERT INTO a VALUES ('x;y');")
+	if len(stmts) != 2 {
+		t.Fatalf("got %d statements: %q", len(stmts), stmts)
+	}
+}
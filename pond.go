@@ -1,6 +1,10 @@
 package vbasedata
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/alitto/pond"
@@ -8,37 +12,54 @@ import (
 )
 
 type PondConfig struct {
-	MaxWorkers  int `yaml:"max_workers" json:"max_workers"`
-	MinWorkers  int `yaml:"min_workers" json:"min_workers"`
-	MaxCapacity int `yaml:"max_capacity" json:"max_capacity"`
-	StopAndWait int `yaml:"stop_and_wait" json:"stop_and_wait"`
+	Name        string `yaml:"name" json:"name"` // 健康检查名称为pond:<name>，未设置时为pond#<序号>
+	MaxWorkers  int    `yaml:"max_workers" json:"max_workers"`
+	MinWorkers  int    `yaml:"min_workers" json:"min_workers"`
+	MaxCapacity int    `yaml:"max_capacity" json:"max_capacity"`
+	StopAndWait int    `yaml:"stop_and_wait" json:"stop_and_wait"`
 }
 
 type Pond struct {
-	pond        *pond.WorkerPool
-	stopAndWait int
+	pond             *pond.WorkerPool
+	stopAndWait      int
+	unregisterHealth func()
 }
 
 func NewPond(c *PondConfig, log *log.Helper) *Pond {
-	if c.MinWorkers == 0 {
-		c.MinWorkers = 2
-	}
-
-	if c.MaxWorkers == 0 {
-		c.MaxWorkers = 10
-	}
+	setPondDefaults(c)
 
-	if c.StopAndWait == 0 {
-		c.StopAndWait = 5
-	}
-
-	return &Pond{
+	p := &Pond{
 		pond: pond.New(c.MaxWorkers, c.MaxCapacity, pond.MinWorkers(c.MinWorkers), pond.PanicHandler(func(i interface{}) {
 			log.Errorf("当前Pond运行的任务异常退出:%v", i)
 		})),
 		stopAndWait: c.StopAndWait,
 	}
+	p.unregisterHealth = DefaultHealth.Register(HealthCheck{
+		Name:  pondHealthName(c),
+		Check: p.Health,
+	})
+	return p
+}
+
+var pondSeq atomic.Int64
 
+// pondHealthName 多个协程池同时存在时健康检查不能同名，否则后注册的会覆盖前一个
+func pondHealthName(c *PondConfig) string {
+	if c.Name != "" {
+		return "pond:" + c.Name
+	}
+	return fmt.Sprintf("pond#%d", pondSeq.Add(1))
+}
+
+// Health 协程池已停止，或所有协程都在运行且有任务排队时返回错误
+func (t *Pond) Health(ctx context.Context) error {
+	if t.pond.Stopped() {
+		return errors.New("协程池已停止")
+	}
+	if t.pond.IdleWorkers() == 0 && t.pond.RunningWorkers() >= t.pond.MaxWorkers() && t.pond.WaitingTasks() > 0 {
+		return fmt.Errorf("协程池已饱和，运行中:%d 排队:%d", t.pond.RunningWorkers(), t.pond.WaitingTasks())
+	}
+	return nil
 }
 
 func (t *Pond) Submit(f func()) {
@@ -50,5 +71,8 @@ func (t *Pond) GetPond() *pond.WorkerPool {
 }
 
 func (t *Pond) Stop() {
+	if t.unregisterHealth != nil {
+		t.unregisterHealth()
+	}
 	t.pond.StopAndWaitFor(time.Duration(t.stopAndWait) * time.Second)
 }
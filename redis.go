@@ -3,6 +3,7 @@ package vbasedata
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/go-kratos/kratos/v2/log"
@@ -10,28 +11,40 @@ import (
 )
 
 type RedisConfig struct {
-	Addr             []string `json:"addr" yaml:"addr"`                           // redis地址
-	Auth             string   `json:"auth" yaml:"auth"`                           // redis密码
-	PoolSize         int      `json:"pool_size" yaml:"pool_size"`                 //连接池最大
-	MaxIdle          int      `json:"max_idle" yaml:"max_idle"`                   //空闲连接数
-	ReadTimeout      int      `json:"read_timeout" yaml:"read_timeout"`           // 读取超时时间，单位秒
-	WriteTimeout     int      `json:"write_timeout" yaml:"write_timeout"`         // 写入超时时间，单位秒
-	MaxIdleTime      int      `json:"max_idle_time" yaml:"max_idle_time"`         // 最大空闲时间
-	DB               int      `json:"db" yaml:"db"`                               // redis数据库
-	MasterName       string   `json:"master_name" yaml:"master_name"`             //哨兵模式下的主节点名称
-	SentinelUsername string   `json:"sentinel_username" yaml:"sentinel_username"` //哨兵模式下的用户名
-	SentinelPassword string   `json:"sentinel_password" yaml:"sentinel_password"` //哨兵模式下的密码
+	Addr             []string     `json:"addr" yaml:"addr"`                           // redis地址
+	Auth             string       `json:"auth" yaml:"auth"`                           // redis密码，支持${env:NAME}、${file:/path}等密钥引用
+	PoolSize         int          `json:"pool_size" yaml:"pool_size"`                 //连接池最大
+	MaxIdle          int          `json:"max_idle" yaml:"max_idle"`                   //空闲连接数
+	ReadTimeout      int          `json:"read_timeout" yaml:"read_timeout"`           // 读取超时时间，单位秒
+	WriteTimeout     int          `json:"write_timeout" yaml:"write_timeout"`         // 写入超时时间，单位秒
+	MaxIdleTime      int          `json:"max_idle_time" yaml:"max_idle_time"`         // 最大空闲时间
+	DB               int          `json:"db" yaml:"db"`                               // redis数据库
+	MasterName       string       `json:"master_name" yaml:"master_name"`             //哨兵模式下的主节点名称
+	SentinelUsername string       `json:"sentinel_username" yaml:"sentinel_username"` //哨兵模式下的用户名
+	SentinelPassword string       `json:"sentinel_password" yaml:"sentinel_password"` //哨兵模式下的密码，支持密钥引用，但只在启动时读取一次，轮换后需要重启
+	Retry            *RetryConfig `json:"retry" yaml:"retry"`                         // 启动连接重试配置
 }
 
 // NewRedis redis连接
 func NewRedis(c *RedisConfig, logger *log.Helper) (redis.UniversalClient, func(), error) {
+	return NewRedisContext(context.Background(), c, logger)
+}
+
+// NewRedisContext redis连接，ctx控制连接检查和重试等待
+func NewRedisContext(ctx context.Context, c *RedisConfig, logger *log.Helper) (redis.UniversalClient, func(), error) {
 	if c == nil {
 		return nil, nil, errors.New("redis配置参数不能为空")
 	}
 
+	// 哨兵密码只在初始化时读取，go-redis的哨兵连接不支持CredentialsProvider，轮换后需要重新创建客户端
+	sentinelPassword, err := ResolveSecret(ctx, c.SentinelPassword)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	logger.Infof("redis配置%+v", c.Addr)
 	//逗号分割，兼容单点和集群两种模式。
-	rdb := redis.NewUniversalClient(&redis.UniversalOptions{
+	opts := &redis.UniversalOptions{
 		PoolSize:         c.PoolSize, //连接池最大
 		MaxIdleConns:     c.MaxIdle,
 		Addrs:            c.Addr,
@@ -41,16 +54,45 @@ func NewRedis(c *RedisConfig, logger *log.Helper) (redis.UniversalClient, func()
 		DB:               c.DB,
 		MasterName:       c.MasterName,
 		SentinelUsername: c.SentinelUsername,
-		SentinelPassword: c.SentinelPassword,
+		SentinelPassword: sentinelPassword,
 		ConnMaxIdleTime:  time.Duration(c.WriteTimeout) * time.Second,
+	}
+	if auth := newSecretValue(c.Auth); auth != nil {
+		// 密码为密钥引用时，新建连接前按间隔重新读取
+		if _, err := auth.Get(ctx); err != nil {
+			return nil, nil, err
+		}
+		opts.Password = ""
+		opts.CredentialsProviderContext = func(ctx context.Context) (string, string, error) {
+			passwd, err := auth.Get(ctx)
+			return "", passwd, err
+		}
+	}
+	if IsSecretRef(c.SentinelPassword) {
+		logger.Warn("redis 哨兵密码为密钥引用，只在启动时读取，轮换后需要重启")
+	}
+	rdb := redis.NewUniversalClient(opts)
+	var pong string
+	err = retryConnect(ctx, c.Retry, logger, "redis", func(ctx context.Context) error {
+		var err error
+		pong, err = rdb.Ping(ctx).Result()
+		return err
 	})
-	pong, err := rdb.Ping(context.Background()).Result()
 	if err != nil {
+		_ = rdb.Close()
 		return nil, nil, err
 	}
 	logger.Infof("redis ping 情况：%v", pong)
+	unregisterHealth := DefaultHealth.Register(HealthCheck{
+		Name:     "redis:" + strings.Join(c.Addr, ","),
+		Critical: true,
+		Check: func(ctx context.Context) error {
+			return rdb.Ping(ctx).Err()
+		},
+	})
 	f := func() {
 		logger.Info("Redis 连接池关闭")
+		unregisterHealth()
 		if err := rdb.Close(); err != nil {
 			logger.Errorf("Redis 连接池关闭失败 %v", err)
 		}
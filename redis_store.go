@@ -0,0 +1,92 @@
+package vbasedata
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+// getDelScript 原子读取并删除，兼容不支持GETDEL的旧版本redis
+var getDelScript = redis.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v then
+	redis.call('DEL', KEYS[1])
+end
+return v
+`)
+
+// RedisStore 基于redis的验证码存储，多实例部署时任一实例生成的验证码都可在其他实例校验
+type RedisStore struct {
+	rdb    redis.UniversalClient
+	prefix string
+	exp    time.Duration
+}
+
+// NewRedisStore 创建redis验证码存储，key前缀取c.KeyPrefix，过期时间取c.StroageExp，c为nil或未设置时使用默认值，不修改c
+func NewRedisStore(rdb redis.UniversalClient, c *CaptchaConfig) *RedisStore {
+	s := &RedisStore{
+		rdb:    rdb,
+		prefix: DefaultCaptchaKeyPrefix,
+		exp:    DefaultCaptchaStroageExp,
+	}
+	if c != nil {
+		if c.KeyPrefix != "" {
+			s.prefix = c.KeyPrefix
+		}
+		if c.StroageExp != 0 {
+			s.exp = c.StroageExp
+		}
+	}
+	return s
+}
+
+func (s *RedisStore) key(id string) string {
+	return s.prefix + id
+}
+
+// Set implements base64Captcha.Store.
+func (s *RedisStore) Set(id string, value string) error {
+	return s.SetContext(context.Background(), id, value)
+}
+
+// Get implements base64Captcha.Store.
+func (s *RedisStore) Get(id string, clear bool) string {
+	v, _ := s.GetContext(context.Background(), id, clear)
+	return v
+}
+
+// Verify implements base64Captcha.Store.
+func (s *RedisStore) Verify(id, answer string, clear bool) bool {
+	return s.VerifyContext(context.Background(), id, answer, clear)
+}
+
+// SetContext 保存答案，过期时间为StroageExp
+func (s *RedisStore) SetContext(ctx context.Context, id string, value string) error {
+	return s.rdb.Set(ctx, s.key(id), value, s.exp).Err()
+}
+
+// GetContext 读取答案，clear为true时原子读取并删除，不存在时返回空字符串
+func (s *RedisStore) GetContext(ctx context.Context, id string, clear bool) (string, error) {
+	var v string
+	var err error
+	if clear {
+		v, err = getDelScript.Run(ctx, s.rdb, []string{s.key(id)}).Text()
+	} else {
+		v, err = s.rdb.Get(ctx, s.key(id)).Result()
+	}
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return v, err
+}
+
+// VerifyContext 校验答案，clear为true时无论是否正确都删除，答案不能重放
+func (s *RedisStore) VerifyContext(ctx context.Context, id, answer string, clear bool) bool {
+	v, err := s.GetContext(ctx, id, clear)
+	if err != nil || v == "" {
+		return false
+	}
+	return v == answer
+}
@@ -0,0 +1,84 @@
+package vbasedata
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/redis/go-redis/v9"
+)
+
+// newTestRedis 设置VB_TEST_REDIS_ADDR时连接真实redis，否则使用进程内的miniredis
+func newTestRedis(t *testing.T) redis.UniversalClient {
+	t.Helper()
+	addr := os.Getenv("VB_TEST_REDIS_ADDR")
+	if addr == "" {
+		addr = miniredis.RunT(t).Addr()
+	}
+	rdb, cleanup, err := NewRedis(&RedisConfig{Addr: []string{addr}, Auth: os.Getenv("VB_TEST_REDIS_AUTH")}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	return rdb
+}
+
+func TestRedisStore(t *testing.T) {
+	rdb := newTestRedis(t)
+	ctx := context.Background()
+	s := NewRedisStore(rdb, &CaptchaConfig{
+		KeyPrefix:  fmt.Sprintf("vbtest:%d:", time.Now().UnixNano()),
+		StroageExp: time.Minute,
+	})
+
+	if err := s.Set("a", "42"); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := rdb.TTL(ctx, s.key("a")).Val(); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("ttl = %v", ttl)
+	}
+	if s.Get("a", false) != "42" {
+		t.Fatal("get without clear")
+	}
+	if !s.Verify("a", "42", true) {
+		t.Fatal("verify should succeed")
+	}
+	if s.Verify("a", "42", true) {
+		t.Fatal("answer should not be replayable")
+	}
+
+	_ = s.SetContext(ctx, "b", "7")
+	if s.VerifyContext(ctx, "b", "8", true) {
+		t.Fatal("wrong answer")
+	}
+	if s.Get("b", false) != "" {
+		t.Fatal("wrong answer should also clear")
+	}
+
+	c := NewCaptcha(&CaptchaConfig{}, s)
+	id, _, ans, err := c.GetCaptCha(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Verify(ctx, id, ans) {
+		t.Fatal("captcha verify should succeed")
+	}
+}
+
+func TestNewRedisStore_Defaults(t *testing.T) {
+	s := NewRedisStore(nil, nil)
+	if s.prefix != DefaultCaptchaKeyPrefix || s.exp != DefaultCaptchaStroageExp {
+		t.Fatalf("prefix = %q, exp = %v", s.prefix, s.exp)
+	}
+	c := &CaptchaConfig{KeyPrefix: "x:"}
+	s = NewRedisStore(nil, c)
+	if s.prefix != "x:" || s.exp != DefaultCaptchaStroageExp {
+		t.Fatalf("prefix = %q, exp = %v", s.prefix, s.exp)
+	}
+	if c.StroageExp != 0 || c.Type != "" || c.Digit != nil {
+		t.Fatalf("caller config mutated: %+v", c)
+	}
+}
@@ -0,0 +1,78 @@
+package vbasedata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// RetryConfig 启动时连接重试配置，用于等待数据库/代理等依赖就绪
+type RetryConfig struct {
+	MaxAttempts    int `yaml:"max_attempts" json:"max_attempts"`       // 最大尝试次数，默认1即不重试
+	InitialBackoff int `yaml:"initial_backoff" json:"initial_backoff"` // 首次重试等待时间 单位：毫秒，默认500，之后指数增长
+	MaxBackoff     int `yaml:"max_backoff" json:"max_backoff"`         // 最大重试等待时间 单位：毫秒，默认10000
+	Deadline       int `yaml:"deadline" json:"deadline"`               // 总超时时间 单位：秒，0为不限制
+}
+
+// retryConnect 按配置重试fn，每次失败都会记录日志，最终失败时返回所有尝试的错误，ctx取消时立即停止；
+// 设置了Deadline时传给fn的ctx带有截止时间，进行中的尝试也会在到期时中断
+func retryConnect(ctx context.Context, c *RetryConfig, logger *log.Helper, target string, fn func(ctx context.Context) error) error {
+	maxAttempts, backoff, maxBackoff := DefaultRetryMaxAttempts, DefaultRetryInitialBackoff*time.Millisecond, DefaultRetryMaxBackoff*time.Millisecond
+	var deadline time.Time
+	if c != nil {
+		if c.MaxAttempts > 0 {
+			maxAttempts = c.MaxAttempts
+		}
+		if c.InitialBackoff > 0 {
+			backoff = time.Duration(c.InitialBackoff) * time.Millisecond
+		}
+		if c.MaxBackoff > 0 {
+			maxBackoff = time.Duration(c.MaxBackoff) * time.Millisecond
+		}
+		if c.Deadline > 0 {
+			deadline = time.Now().Add(time.Duration(c.Deadline) * time.Second)
+		}
+	}
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	var errs []error
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			if attempt > 1 {
+				logger.Infof("%v 第%d次连接成功", target, attempt)
+			}
+			return nil
+		}
+		logger.Errorf("%v 第%d/%d次连接失败,%v", target, attempt, maxAttempts, err)
+		errs = append(errs, fmt.Errorf("第%d次: %w", attempt, err))
+
+		if attempt >= maxAttempts {
+			break
+		}
+		if !deadline.IsZero() && time.Now().Add(backoff).After(deadline) {
+			errs = append(errs, fmt.Errorf("超过总超时时间%ds", c.Deadline))
+			break
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			errs = append(errs, ctx.Err())
+			return fmt.Errorf("%v 连接失败: %w", target, errors.Join(errs...))
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	return fmt.Errorf("%v 连接失败: %w", target, errors.Join(errs...))
+}
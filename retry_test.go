@@ -0,0 +1,65 @@
+package vbasedata
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryConnect(t *testing.T) {
+	attempts := 0
+	err := retryConnect(context.Background(), &RetryConfig{MaxAttempts: 3, InitialBackoff: 1}, newTestLogger(), "test", func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("not ready")
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Fatalf("attempts = %d, err = %v", attempts, err)
+	}
+
+	attempts = 0
+	err = retryConnect(context.Background(), &RetryConfig{MaxAttempts: 2, InitialBackoff: 1}, newTestLogger(), "test", func(ctx context.Context) error {
+		attempts++
+		return errors.New("refused")
+	})
+	if err == nil || attempts != 2 || strings.Count(err.Error(), "refused") != 2 {
+		t.Fatalf("attempts = %d, err = %v", attempts, err)
+	}
+}
+
+func TestRetryConnect_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	start := time.Now()
+	err := retryConnect(ctx, &RetryConfig{MaxAttempts: 10, InitialBackoff: 10000}, newTestLogger(), "test", func(ctx context.Context) error {
+		attempts++
+		time.AfterFunc(20*time.Millisecond, cancel)
+		return errors.New("refused")
+	})
+	if !errors.Is(err, context.Canceled) || attempts != 1 {
+		t.Fatalf("attempts = %d, err = %v", attempts, err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("cancel did not interrupt the backoff")
+	}
+}
+
+func TestRetryConnect_Deadline(t *testing.T) {
+	attempts := 0
+	start := time.Now()
+	err := retryConnect(context.Background(), &RetryConfig{MaxAttempts: 10, InitialBackoff: 1, Deadline: 1}, newTestLogger(), "test", func(ctx context.Context) error {
+		attempts++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || attempts != 1 {
+		t.Fatalf("attempts = %d, err = %v", attempts, err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("deadline did not interrupt the attempt")
+	}
+}
@@ -0,0 +1,151 @@
+package vbasedata
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretRefreshInterval 密钥引用的重新读取间隔，轮换后的密钥在此间隔内生效于新建的连接，应在初始化客户端前设置
+var SecretRefreshInterval = time.Minute
+
+// 密钥引用的格式为${scheme:ref}，如${env:DB_PASSWORD}、${file:/run/secrets/db}，必须是完整的值。
+// 明文恰好以${开头时写成$${...}，解析时去掉一个$
+const (
+	secretRefPrefix = "${"
+	secretRefSuffix = "}"
+	secretEscape    = "$${"
+)
+
+// SecretProvider 密钥提供者，ref为引用中scheme:之后的部分
+type SecretProvider interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// SecretProviderFunc 函数形式的密钥提供者
+type SecretProviderFunc func(ctx context.Context, ref string) (string, error)
+
+// Resolve implements SecretProvider.
+func (f SecretProviderFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   = map[string]SecretProvider{
+		"env":  SecretProviderFunc(resolveEnvSecret),
+		"file": SecretProviderFunc(resolveFileSecret),
+	}
+)
+
+// RegisterSecretProvider 注册自定义密钥提供者，配置中以${scheme:ref}的形式引用。
+// 返回的函数用于注销，恢复注册前的提供者
+func RegisterSecretProvider(scheme string, p SecretProvider) func() {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+	prev, hadPrev := secretProviders[scheme]
+	secretProviders[scheme] = p
+	return func() {
+		secretProvidersMu.Lock()
+		defer secretProvidersMu.Unlock()
+		if hadPrev {
+			secretProviders[scheme] = prev
+		} else {
+			delete(secretProviders, scheme)
+		}
+	}
+}
+
+// resolveEnvSecret ${env:NAME} 读取环境变量
+func resolveEnvSecret(_ context.Context, name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("环境变量%v不存在", name)
+	}
+	return v, nil
+}
+
+// resolveFileSecret ${file:/path} 读取文件内容，去掉末尾换行
+func resolveFileSecret(_ context.Context, path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// lookupSecretRef 判断value是否为已注册scheme的密钥引用，返回提供者、scheme和ref
+func lookupSecretRef(value string) (SecretProvider, string, string, bool) {
+	if !strings.HasPrefix(value, secretRefPrefix) || !strings.HasSuffix(value, secretRefSuffix) {
+		return nil, "", "", false
+	}
+	inner := value[len(secretRefPrefix) : len(value)-len(secretRefSuffix)]
+	scheme, ref, ok := strings.Cut(inner, ":")
+	if !ok || scheme == "" {
+		return nil, "", "", false
+	}
+	secretProvidersMu.RLock()
+	p, ok := secretProviders[scheme]
+	secretProvidersMu.RUnlock()
+	return p, scheme, ref, ok
+}
+
+// IsSecretRef value是否为密钥引用，scheme未注册时视为明文
+func IsSecretRef(value string) bool {
+	_, _, _, ok := lookupSecretRef(value)
+	return ok
+}
+
+// ResolveSecret 解析密钥引用，不是引用时原样返回，$${开头的明文去掉转义
+func ResolveSecret(ctx context.Context, value string) (string, error) {
+	if strings.HasPrefix(value, secretEscape) {
+		return value[1:], nil
+	}
+	p, scheme, ref, ok := lookupSecretRef(value)
+	if !ok {
+		return value, nil
+	}
+	v, err := p.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("密钥引用%v:读取失败: %w", scheme, err)
+	}
+	return v, nil
+}
+
+// secretValue 缓存的密钥，超过刷新间隔后重新读取
+type secretValue struct {
+	raw     string
+	mu      sync.Mutex
+	value   string
+	fetched time.Time
+}
+
+// newSecretValue value是普通明文时返回nil，调用方直接使用；转义的明文也返回secretValue以去掉转义
+func newSecretValue(value string) *secretValue {
+	if !IsSecretRef(value) && !strings.HasPrefix(value, secretEscape) {
+		return nil
+	}
+	return &secretValue{raw: value}
+}
+
+// Get 返回当前密钥，重新读取失败时沿用上次的值
+func (s *secretValue) Get(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.fetched.IsZero() && time.Since(s.fetched) < SecretRefreshInterval {
+		return s.value, nil
+	}
+	v, err := ResolveSecret(ctx, s.raw)
+	if err != nil {
+		if s.fetched.IsZero() {
+			return "", err
+		}
+		return s.value, nil
+	}
+	s.value = v
+	s.fetched = time.Now()
+	return v, nil
+}
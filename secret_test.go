@@ -0,0 +1,93 @@
+package vbasedata
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolveSecret(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("VB_SECRET_TEST", "from-env")
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"plain":                  "plain",
+		"p@ss:word":              "p@ss:word",
+		"env:VB_SECRET_TEST":     "env:VB_SECRET_TEST",
+		"file:x":                 "file:x",
+		"${env:VB_SECRET_TEST}":  "from-env",
+		"${file:" + path + "}":   "from-file",
+		"$${env:VB_SECRET_TEST}": "${env:VB_SECRET_TEST}",
+		"${env:VB_SECRET_TEST":   "${env:VB_SECRET_TEST",
+		"":                       "",
+	}
+	for in, want := range tests {
+		got, err := ResolveSecret(ctx, in)
+		if err != nil || got != want {
+			t.Errorf("ResolveSecret(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+
+	if _, err := ResolveSecret(ctx, "${env:VB_SECRET_MISSING}"); err == nil {
+		t.Fatal("missing env should fail")
+	}
+}
+
+func TestSecretValueRefresh(t *testing.T) {
+	old := SecretRefreshInterval
+	SecretRefreshInterval = 20 * time.Millisecond
+	defer func() { SecretRefreshInterval = old }()
+
+	current := "v1"
+	var fail bool
+	unregister := RegisterSecretProvider("vbtest", SecretProviderFunc(func(ctx context.Context, ref string) (string, error) {
+		if fail {
+			return "", errors.New("unavailable")
+		}
+		return ref + ":" + current, nil
+	}))
+	t.Cleanup(unregister)
+
+	if newSecretValue("plain") != nil {
+		t.Fatal("plain value should not be a secret ref")
+	}
+	if newSecretValue("vbtest:db") != nil {
+		t.Fatal("value without ${} should stay plaintext")
+	}
+	s := newSecretValue("${vbtest:db}")
+	ctx := context.Background()
+	if v, _ := s.Get(ctx); v != "db:v1" {
+		t.Fatalf("Get = %q", v)
+	}
+
+	current = "v2"
+	if v, _ := s.Get(ctx); v != "db:v1" {
+		t.Fatalf("cached Get = %q", v)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if v, _ := s.Get(ctx); v != "db:v2" {
+		t.Fatalf("rotated Get = %q", v)
+	}
+
+	// 读取失败时沿用上次的值
+	fail = true
+	time.Sleep(30 * time.Millisecond)
+	if v, err := s.Get(ctx); err != nil || v != "db:v2" {
+		t.Fatalf("Get after failure = %q, %v", v, err)
+	}
+
+	if v, _ := newSecretValue("$${vbtest:db}").Get(ctx); v != "${vbtest:db}" {
+		t.Fatalf("escaped Get = %q", v)
+	}
+	unregister()
+	if IsSecretRef("${vbtest:db}") {
+		t.Fatal("provider should be unregistered")
+	}
+}
@@ -1,11 +1,147 @@
 package vbasedata
 
+import (
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/middleware/logging"
+	"github.com/go-kratos/kratos/v2/middleware/metadata"
+	"github.com/go-kratos/kratos/v2/middleware/recovery"
+	"github.com/go-kratos/kratos/v2/middleware/tracing"
+	"github.com/go-kratos/kratos/v2/transport/grpc"
+	"github.com/go-kratos/kratos/v2/transport/http"
+	grpcgo "google.golang.org/grpc"
+)
+
 type Http struct {
-	Addr    string `yaml:"addr" json:"addr"`
-	Timeout string `yaml:"timeout" json:"timeout"`
+	Addr        string            `yaml:"addr" json:"addr"`
+	Timeout     string            `yaml:"timeout" json:"timeout"`             // 请求超时，Go时长格式如1s、500ms
+	TLS         *TLSConfig        `yaml:"tls" json:"tls"`                     // 服务端证书cert_file/key_file，设置ca_file时要求并校验客户端证书
+	MaxBodySize int64             `yaml:"max_body_size" json:"max_body_size"` // 请求体最大字节数，0为不限制
+	Middleware  *MiddlewareConfig `yaml:"middleware" json:"middleware"`       // 中间件开关，recovery默认启用
 }
 
 type Grpc struct {
-	Addr    string `yaml:"addr" json:"addr"`
-	Timeout string `yaml:"timeout" json:"timeout"`
+	Addr       string            `yaml:"addr" json:"addr"`
+	Timeout    string            `yaml:"timeout" json:"timeout"`           // 请求超时，Go时长格式如1s、500ms
+	TLS        *TLSConfig        `yaml:"tls" json:"tls"`                   // 服务端证书cert_file/key_file，设置ca_file时要求并校验客户端证书
+	MaxMsgSize int               `yaml:"max_msg_size" json:"max_msg_size"` // 收发消息最大字节数，0使用grpc默认值(接收4MB)
+	Middleware *MiddlewareConfig `yaml:"middleware" json:"middleware"`     // 中间件开关，recovery默认启用
+}
+
+// MiddlewareConfig 服务端中间件开关
+type MiddlewareConfig struct {
+	DisableRecovery bool `yaml:"disable_recovery" json:"disable_recovery"` // 关闭panic恢复，默认启用
+	Logging         bool `yaml:"logging" json:"logging"`                   // 请求日志
+	Tracing         bool `yaml:"tracing" json:"tracing"`                   // 链路追踪，使用全局TracerProvider
+	Metadata        bool `yaml:"metadata" json:"metadata"`                 // 元数据透传
+}
+
+// middlewares 按开关组装中间件，extra追加在最后
+func (m *MiddlewareConfig) middlewares(logger *log.Helper, extra []middleware.Middleware) []middleware.Middleware {
+	if m == nil {
+		m = &MiddlewareConfig{}
+	}
+	var ms []middleware.Middleware
+	if !m.DisableRecovery {
+		ms = append(ms, recovery.Recovery())
+	}
+	if m.Tracing {
+		ms = append(ms, tracing.Server())
+	}
+	if m.Metadata {
+		ms = append(ms, metadata.Server())
+	}
+	if m.Logging && logger != nil {
+		ms = append(ms, logging.Server(logger.Logger()))
+	}
+	return append(ms, extra...)
+}
+
+// parseServerTimeout 解析超时时长，为空时返回0由kratos使用默认值
+func parseServerTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return 0, fmt.Errorf("timeout不是合法的时长:%v", timeout)
+	}
+	return d, nil
+}
+
+// NewHttpServerOptions 将Http配置转换为kratos http服务选项，extra为追加的业务中间件
+func NewHttpServerOptions(c *Http, logger *log.Helper, extra ...middleware.Middleware) ([]http.ServerOption, error) {
+	if c == nil {
+		return nil, errors.New("http配置参数不能为空")
+	}
+	var opts []http.ServerOption
+	if c.Addr != "" {
+		opts = append(opts, http.Address(c.Addr))
+	}
+	timeout, err := parseServerTimeout(c.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		opts = append(opts, http.Timeout(timeout))
+	}
+	if c.TLS != nil {
+		tlsConfig, err := c.TLS.ServerConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, http.TLSConfig(tlsConfig))
+	}
+	if c.MaxBodySize > 0 {
+		opts = append(opts, http.Filter(func(next nethttp.Handler) nethttp.Handler {
+			return nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				r.Body = nethttp.MaxBytesReader(w, r.Body, c.MaxBodySize)
+				next.ServeHTTP(w, r)
+			})
+		}))
+	}
+	if ms := c.Middleware.middlewares(logger, extra); len(ms) > 0 {
+		opts = append(opts, http.Middleware(ms...))
+	}
+	return opts, nil
+}
+
+// NewGrpcServerOptions 将Grpc配置转换为kratos grpc服务选项，extra为追加的业务中间件
+func NewGrpcServerOptions(c *Grpc, logger *log.Helper, extra ...middleware.Middleware) ([]grpc.ServerOption, error) {
+	if c == nil {
+		return nil, errors.New("grpc配置参数不能为空")
+	}
+	var opts []grpc.ServerOption
+	if c.Addr != "" {
+		opts = append(opts, grpc.Address(c.Addr))
+	}
+	timeout, err := parseServerTimeout(c.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		opts = append(opts, grpc.Timeout(timeout))
+	}
+	if c.TLS != nil {
+		tlsConfig, err := c.TLS.ServerConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.TLSConfig(tlsConfig))
+	}
+	if c.MaxMsgSize > 0 {
+		opts = append(opts, grpc.Options(
+			grpcgo.MaxRecvMsgSize(c.MaxMsgSize),
+			grpcgo.MaxSendMsgSize(c.MaxMsgSize),
+		))
+	}
+	if ms := c.Middleware.middlewares(logger, extra); len(ms) > 0 {
+		opts = append(opts, grpc.Middleware(ms...))
+	}
+	return opts, nil
 }
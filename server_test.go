@@ -0,0 +1,82 @@
+package vbasedata
+
+import (
+	"context"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport/http"
+)
+
+func TestNewHttpServerOptions(t *testing.T) {
+	if _, err := NewHttpServerOptions(&Http{Timeout: "10"}, newTestLogger()); err == nil {
+		t.Fatal("timeout without unit should fail")
+	}
+	if _, err := NewHttpServerOptions(&Http{TLS: &TLSConfig{CAFile: "ca.pem"}}, newTestLogger()); err == nil {
+		t.Fatal("tls without cert should fail")
+	}
+
+	opts, err := NewHttpServerOptions(&Http{
+		Addr:        ":0",
+		Timeout:     "1s",
+		MaxBodySize: 8,
+		Middleware:  &MiddlewareConfig{Logging: true},
+	}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := http.NewServer(opts...)
+	srv.HandleFunc("/echo", func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			w.WriteHeader(nethttp.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(nethttp.StatusOK)
+	})
+
+	for body, want := range map[string]int{
+		"small":            nethttp.StatusOK,
+		"much too large!!": nethttp.StatusRequestEntityTooLarge,
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(nethttp.MethodPost, "/echo", strings.NewReader(body)))
+		if rec.Code != want {
+			t.Errorf("body %q: code = %d, want %d", body, rec.Code, want)
+		}
+	}
+}
+
+func TestNewGrpcServerOptions(t *testing.T) {
+	if _, err := NewGrpcServerOptions(&Grpc{Timeout: "abc"}, newTestLogger()); err == nil {
+		t.Fatal("invalid timeout should fail")
+	}
+	opts, err := NewGrpcServerOptions(&Grpc{Addr: ":0", Timeout: "2s", MaxMsgSize: 1 << 20}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opts) != 4 {
+		t.Fatalf("len(opts) = %d", len(opts))
+	}
+}
+
+func TestMiddlewareConfig_Recovery(t *testing.T) {
+	panicking := func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("boom")
+	}
+	// 只开启其他中间件时recovery仍然生效
+	for _, m := range []*MiddlewareConfig{nil, {Logging: true, Metadata: true}} {
+		h := middleware.Chain(m.middlewares(newTestLogger(), nil)...)(panicking)
+		if _, err := h(context.Background(), nil); err == nil {
+			t.Fatalf("%+v: panic should be recovered as error", m)
+		}
+	}
+
+	ms := (&MiddlewareConfig{DisableRecovery: true}).middlewares(newTestLogger(), nil)
+	if len(ms) != 0 {
+		t.Fatalf("disable_recovery: %d middlewares", len(ms))
+	}
+}
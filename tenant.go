@@ -0,0 +1,304 @@
+package vbasedata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+// TenantPlaceholder 模板中DBName/DBPath的租户占位符
+const TenantPlaceholder = "{tenant}"
+
+var tenantIDRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// TenantConfig 多租户连接池配置
+type TenantConfig struct {
+	MaxTenants  int `yaml:"max_tenants" json:"max_tenants"`   // 同时打开的租户连接池上限，超出时关闭最久未使用的，默认100
+	IdleTimeout int `yaml:"idle_timeout" json:"idle_timeout"` // 租户连接池空闲多久后关闭 单位：秒，默认600
+	CloseDelay  int `yaml:"close_delay" json:"close_delay"`   // 淘汰后延迟关闭的时间，使已取得连接的调用方完成查询 单位：秒，默认30
+	OpenTimeout int `yaml:"open_timeout" json:"open_timeout"` // 打开租户连接池的超时时间，不受单个请求取消的影响 单位：秒，默认30
+}
+
+type tenantCtxKey struct{}
+
+// WithTenant 将租户ID放入ctx
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, tenantID)
+}
+
+// TenantFromContext 从ctx中获取租户ID
+func TenantFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantCtxKey{}).(string)
+	return id, ok && id != ""
+}
+
+type tenantPool struct {
+	db       *gorm.DB
+	close    func()
+	lastUsed atomic.Int64 // unix纳秒
+}
+
+func (p *tenantPool) touch() {
+	p.lastUsed.Store(time.Now().UnixNano())
+}
+
+// TenantResolver 按ctx中的租户ID返回对应的gorm客户端，连接池按需打开。
+// mysql按库隔离(DBName)，pg按schema隔离(search_path)，sqlite按文件隔离(DBPath)。
+// 超出上限或空闲超时的连接池先移出缓存，延迟CloseDelay后再关闭，避免正在使用的*gorm.DB被关闭
+type TenantResolver struct {
+	template    *GormConfig
+	logger      *log.Helper
+	pools       *lru.Cache[string, *tenantPool]
+	group       singleflight.Group
+	idle        time.Duration
+	closeDelay  time.Duration
+	openTimeout time.Duration
+
+	// addMu 保证检查closed与加入缓存是原子的，加入时可能触发retire获取mu，因此不能复用mu；加锁顺序为addMu、mu
+	addMu    sync.Mutex
+	mu       sync.Mutex
+	retiring map[*tenantPool]*time.Timer
+	closed   bool
+	stop     chan struct{}
+}
+
+// NewTenantResolver 以template为模板创建租户路由，返回的清理函数会关闭所有租户连接池
+func NewTenantResolver(template *GormConfig, c *TenantConfig, logger *log.Helper) (*TenantResolver, func(), error) {
+	if template == nil {
+		return nil, nil, errors.New("GORM配置参数不能为空")
+	}
+	if c == nil {
+		c = &TenantConfig{}
+	}
+	setTenantDefaults(c)
+
+	r := &TenantResolver{
+		template:    template,
+		logger:      logger,
+		idle:        time.Duration(c.IdleTimeout) * time.Second,
+		closeDelay:  time.Duration(c.CloseDelay) * time.Second,
+		openTimeout: time.Duration(c.OpenTimeout) * time.Second,
+		retiring:    make(map[*tenantPool]*time.Timer),
+		stop:        make(chan struct{}),
+	}
+	// 淘汰回调在缓存的锁内执行，只登记延迟关闭，不做耗时操作
+	pools, err := lru.NewWithEvict[string, *tenantPool](c.MaxTenants, r.retire)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.pools = pools
+	go r.expireLoop()
+
+	return r, r.close, nil
+}
+
+// retire 延迟关闭被淘汰的连接池，resolver关闭后直接关闭
+func (r *TenantResolver) retire(tenantID string, p *tenantPool) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		r.logger.Infof("租户:%v 连接池关闭", tenantID)
+		p.close()
+		return
+	}
+	defer r.mu.Unlock()
+	r.retiring[p] = time.AfterFunc(r.closeDelay, func() {
+		r.mu.Lock()
+		delete(r.retiring, p)
+		r.mu.Unlock()
+		r.logger.Infof("租户:%v 连接池关闭", tenantID)
+		p.close()
+	})
+}
+
+// expireLoop 定期淘汰空闲超时的连接池
+func (r *TenantResolver) expireLoop() {
+	interval := r.idle / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			deadline := time.Now().Add(-r.idle).UnixNano()
+			for _, tenantID := range r.pools.Keys() {
+				if p, ok := r.pools.Peek(tenantID); ok && p.lastUsed.Load() < deadline {
+					r.pools.Remove(tenantID)
+				}
+			}
+		}
+	}
+}
+
+// close 停止空闲淘汰并关闭所有连接池，包括等待延迟关闭的
+func (r *TenantResolver) close() {
+	r.addMu.Lock()
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		r.addMu.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.stop)
+	pending := r.retiring
+	r.retiring = nil
+	r.mu.Unlock()
+	r.addMu.Unlock()
+
+	for p, timer := range pending {
+		// 已触发的定时器会自行关闭
+		if timer.Stop() {
+			p.close()
+		}
+	}
+	r.pools.Purge()
+}
+
+// DB 返回ctx中租户对应的gorm客户端，绑定了ctx
+func (r *TenantResolver) DB(ctx context.Context) (*gorm.DB, error) {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, errors.New("ctx中缺少租户ID")
+	}
+	db, err := r.Get(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return db.WithContext(ctx), nil
+}
+
+// Get 返回指定租户的gorm客户端，首次使用时打开连接池，mysql库不存在时自动创建
+func (r *TenantResolver) Get(ctx context.Context, tenantID string) (*gorm.DB, error) {
+	if !tenantIDRe.MatchString(tenantID) {
+		return nil, fmt.Errorf("租户ID不合法:%q", tenantID)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if p, ok := r.pools.Get(tenantID); ok {
+		p.touch()
+		return p.db, nil
+	}
+
+	// 打开过程由所有等待的调用方共享，不随某个调用方的ctx取消，超时由OpenTimeout控制
+	ch := r.group.DoChan(tenantID, func() (interface{}, error) {
+		if p, ok := r.pools.Get(tenantID); ok {
+			return p, nil
+		}
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.openTimeout)
+		defer cancel()
+		p, err := r.open(ctx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		r.addMu.Lock()
+		defer r.addMu.Unlock()
+		if r.closed {
+			p.close()
+			return nil, errors.New("租户路由已关闭")
+		}
+		p.touch()
+		r.pools.Add(tenantID, p)
+		return p, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		p := res.Val.(*tenantPool)
+		p.touch()
+		return p.db, nil
+	}
+}
+
+// Len 当前打开的租户连接池数量
+func (r *TenantResolver) Len() int {
+	return r.pools.Len()
+}
+
+func (r *TenantResolver) open(ctx context.Context, tenantID string) (*tenantPool, error) {
+	c := r.tenantConfig(tenantID)
+	db, closeFn, err := NewGormContext(ctx, c, r.logger)
+	if err != nil {
+		return nil, fmt.Errorf("租户:%v 连接失败: %w", tenantID, err)
+	}
+	if c.Type == "pg" {
+		schema := c.Params["search_path"]
+		if err := db.WithContext(ctx).Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", quotePGIdent(schema))).Error; err != nil {
+			closeFn()
+			return nil, fmt.Errorf("租户:%v 创建schema失败: %w", tenantID, err)
+		}
+	}
+	r.logger.Infof("租户:%v 连接池已打开", tenantID)
+	return &tenantPool{db: db, close: closeFn}, nil
+}
+
+// tenantConfig 根据模板生成租户的配置
+func (r *TenantResolver) tenantConfig(tenantID string) *GormConfig {
+	c := *r.template
+	c.Params = make(map[string]string, len(r.template.Params)+1)
+	for k, v := range r.template.Params {
+		c.Params[k] = v
+	}
+	if r.template.Logconfig != nil {
+		l := *r.template.Logconfig
+		c.Logconfig = &l
+	}
+	if r.template.Conns != nil {
+		conns := *r.template.Conns
+		c.Conns = &conns
+	}
+	if c.Name == "" {
+		c.Name = "tenant"
+	}
+	c.Name += ":" + tenantID
+	// 单个租户不可用不应影响整体就绪状态，模板未显式设置degraded时不注册健康检查
+	if c.Health != GormHealthDegraded {
+		c.Health = GormHealthOff
+	}
+
+	switch c.Type {
+	case "mysql":
+		if strings.Contains(c.DBName, TenantPlaceholder) {
+			c.DBName = strings.ReplaceAll(c.DBName, TenantPlaceholder, tenantID)
+		} else {
+			c.DBName = tenantID
+		}
+	case "pg":
+		schema := tenantID
+		if sp, ok := c.Params["search_path"]; ok && strings.Contains(sp, TenantPlaceholder) {
+			schema = strings.ReplaceAll(sp, TenantPlaceholder, tenantID)
+		}
+		c.Params["search_path"] = schema
+	default:
+		if strings.Contains(c.DBPath, TenantPlaceholder) {
+			c.DBPath = strings.ReplaceAll(c.DBPath, TenantPlaceholder, tenantID)
+		} else {
+			dir := "."
+			if c.DBPath != "" {
+				dir = filepath.Dir(c.DBPath)
+			}
+			c.DBPath = filepath.Join(dir, tenantID+".db")
+		}
+	}
+	return &c
+}
@@ -0,0 +1,127 @@
+package vbasedata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTenantResolver(t *testing.T) {
+	dir := t.TempDir()
+	r, closeFn, err := NewTenantResolver(&GormConfig{
+		Name:   "tenant",
+		DBPath: filepath.Join(dir, "{tenant}.db"),
+	}, &TenantConfig{MaxTenants: 2}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFn()
+	r.closeDelay = 100 * time.Millisecond
+
+	if _, err := r.DB(context.Background()); err == nil {
+		t.Fatal("expected error without tenant")
+	}
+	if _, err := r.DB(WithTenant(context.Background(), "../etc")); err == nil {
+		t.Fatal("expected error for invalid tenant id")
+	}
+
+	for _, id := range []string{"a", "b"} {
+		db, err := r.DB(WithTenant(context.Background(), id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Exec("CREATE TABLE t (id INTEGER)").Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if names := DefaultHealth.Names(); slices.Contains(names, "db:tenant:a") {
+		t.Fatalf("tenant pool registered a health check: %v", names)
+	}
+	a1, _ := r.Get(context.Background(), "a")
+	a2, _ := r.Get(context.Background(), "a")
+	if a1 != a2 {
+		t.Fatal("expected cached pool for tenant a")
+	}
+
+	// 超过上限时淘汰最久未使用的租户b，延迟关闭前已取得的客户端仍可使用
+	b, _ := r.Get(context.Background(), "b")
+	_, _ = r.Get(context.Background(), "a")
+	if _, err := r.Get(context.Background(), "c"); err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 2 {
+		t.Fatalf("open pools = %d, want 2", r.Len())
+	}
+	if err := b.Exec("INSERT INTO t (id) VALUES (1)").Error; err != nil {
+		t.Fatalf("evicted pool closed too early: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if _, ok := GormStats()["tenant:b"]; ok {
+		t.Fatal("evicted tenant pool should be closed after close_delay")
+	}
+
+	// 取消的请求直接返回，不影响之后的打开
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.Get(ctx, "d"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled get = %v", err)
+	}
+	if _, err := r.Get(context.Background(), "d"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTenantResolver_Close(t *testing.T) {
+	r, closeFn, err := NewTenantResolver(&GormConfig{
+		Name:   "tenantclose",
+		DBPath: filepath.Join(t.TempDir(), "{tenant}.db"),
+	}, &TenantConfig{MaxTenants: 1}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = r.Get(context.Background(), "a")
+	_, _ = r.Get(context.Background(), "b") // a等待延迟关闭
+	closeFn()
+	closeFn()
+	for name := range GormStats() {
+		if strings.HasPrefix(name, "tenantclose:") {
+			t.Fatalf("pool %v still open after cleanup", name)
+		}
+	}
+	select {
+	case <-r.stop:
+	default:
+		t.Fatal("expire loop not stopped")
+	}
+}
+
+func TestTenantResolver_CloseWhileOpening(t *testing.T) {
+	r, closeFn, err := NewTenantResolver(&GormConfig{
+		Name:   "tenantrace",
+		DBPath: filepath.Join(t.TempDir(), "{tenant}.db"),
+	}, &TenantConfig{MaxTenants: 2}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _ = r.Get(context.Background(), fmt.Sprintf("t%d", i))
+		}(i)
+	}
+	closeFn()
+	wg.Wait()
+	for name := range GormStats() {
+		if strings.HasPrefix(name, "tenantrace:") {
+			t.Fatalf("pool %v opened during close was leaked", name)
+		}
+	}
+}
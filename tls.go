@@ -0,0 +1,135 @@
+package vbasedata
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSConfig TLS证书配置
+type TLSConfig struct {
+	CAFile     string `yaml:"ca_file" json:"ca_file"`         // CA证书文件
+	CertFile   string `yaml:"cert_file" json:"cert_file"`     // 客户端证书文件，双向认证时使用
+	KeyFile    string `yaml:"key_file" json:"key_file"`       // 客户端私钥文件，双向认证时使用
+	ServerName string `yaml:"server_name" json:"server_name"` // 校验的服务端名称，默认使用连接地址；pg不支持
+	VerifyMode string `yaml:"verify_mode" json:"verify_mode"` // 校验模式 full:校验证书和主机名 ca:只校验证书 skip:不校验，默认full
+}
+
+const (
+	TLSVerifyFull = "full"
+	TLSVerifyCA   = "ca"
+	TLSVerifySkip = "skip"
+)
+
+func (t *TLSConfig) verifyMode() string {
+	if t.VerifyMode == "" {
+		return TLSVerifyFull
+	}
+	return t.VerifyMode
+}
+
+// checkVerifyMode 校验VerifyMode，mysql和pg共用
+func (t *TLSConfig) checkVerifyMode() error {
+	switch t.verifyMode() {
+	case TLSVerifyFull, TLSVerifyCA, TLSVerifySkip:
+		return nil
+	}
+	return fmt.Errorf("未知的TLS校验模式:%v", t.VerifyMode)
+}
+
+// ClientConfig 生成客户端tls.Config
+func (t *TLSConfig) ClientConfig() (*tls.Config, error) {
+	if err := t.checkVerifyMode(); err != nil {
+		return nil, err
+	}
+	conf := &tls.Config{
+		ServerName: t.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA证书解析失败:%v", t.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	switch t.verifyMode() {
+	case TLSVerifyFull:
+	case TLSVerifySkip:
+		conf.InsecureSkipVerify = true
+	case TLSVerifyCA:
+		// 跳过默认校验，只校验证书链，不校验主机名
+		conf.InsecureSkipVerify = true
+		roots := conf.RootCAs
+		conf.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("服务端未提供证书")
+			}
+			certs := make([]*x509.Certificate, 0, len(rawCerts))
+			for _, raw := range rawCerts {
+				cert, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				certs = append(certs, cert)
+			}
+			opts := x509.VerifyOptions{
+				Roots:         roots,
+				Intermediates: x509.NewCertPool(),
+			}
+			for _, cert := range certs[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := certs[0].Verify(opts)
+			return err
+		}
+	}
+	return conf, nil
+}
+
+// ServerConfig 转换为服务端tls配置，设置CAFile时开启双向认证
+func (t *TLSConfig) ServerConfig() (*tls.Config, error) {
+	if t.CertFile == "" || t.KeyFile == "" {
+		return nil, errors.New("服务端TLS需要cert_file和key_file")
+	}
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("加载服务端证书失败: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA证书无效:%v", t.CAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		if t.verifyMode() == TLSVerifySkip {
+			cfg.ClientAuth = tls.RequestClientCert
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,127 @@
+package vbasedata
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// App.Trace支持的模式
+const (
+	TraceNone   = "none"   // 不采集，同空值
+	TraceStdout = "stdout" // 输出到标准输出，用于本地调试
+	TraceMemory = "memory" // 保存到MemoryTraceExporter，用于测试
+)
+
+// MemoryTraceExporter memory模式下span写入的导出器，TracerProvider关闭时清空
+var MemoryTraceExporter = tracetest.NewInMemoryExporter()
+
+// validTraceMode 校验App.Trace，支持none/stdout/memory/otlp-http://host:port[/path]/otlp-https://...
+func validTraceMode(mode string) error {
+	switch mode {
+	case "", TraceNone, TraceStdout, TraceMemory:
+		return nil
+	}
+	if strings.HasPrefix(mode, "otlp-http://") || strings.HasPrefix(mode, "otlp-https://") {
+		u, err := url.Parse(strings.TrimPrefix(mode, "otlp-"))
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("otlp地址不合法:%v", mode)
+		}
+		return nil
+	}
+	return fmt.Errorf("未知的链路追踪模式:%v", mode)
+}
+
+// newTraceExporter 按模式创建导出器
+func newTraceExporter(ctx context.Context, mode string) (sdktrace.SpanExporter, error) {
+	switch mode {
+	case TraceStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case TraceMemory:
+		return MemoryTraceExporter, nil
+	}
+	u, err := url.Parse(strings.TrimPrefix(mode, "otlp-"))
+	if err != nil {
+		return nil, err
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host)}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if u.Path != "" && u.Path != "/" {
+		opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+	}
+	return otlptracehttp.New(ctx, opts...)
+}
+
+// NewTracerProvider 按App.Trace创建TracerProvider并设置为全局，服务名取AppName，环境取Env。
+// Trace为空或none时返回nil，返回的清理函数会导出剩余的span并关闭，之后恢复原来的全局TracerProvider
+func NewTracerProvider(c *App, logger *log.Helper) (*sdktrace.TracerProvider, func(), error) {
+	return NewTracerProviderContext(context.Background(), c, logger)
+}
+
+// NewTracerProviderContext 同NewTracerProvider，ctx传递给导出器的创建，关闭时沿用ctx中的值(不受其取消影响)并限时5秒
+func NewTracerProviderContext(ctx context.Context, c *App, logger *log.Helper) (*sdktrace.TracerProvider, func(), error) {
+	if c == nil || c.Trace == "" || c.Trace == TraceNone {
+		return nil, func() {}, nil
+	}
+	if err := validTraceMode(c.Trace); err != nil {
+		return nil, nil, err
+	}
+	exporter, err := newTraceExporter(ctx, c.Trace)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(c.AppName),
+		semconv.DeploymentEnvironment(c.Env),
+	))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	if c.Trace == TraceMemory {
+		// 内存模式同步导出，便于测试中立即读取
+		opts = append(opts, sdktrace.WithSyncer(exporter))
+	} else {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	logger.Infof("链路追踪:%v 已启用", c.Trace)
+
+	f := func() {
+		logger.Info("链路追踪关闭")
+		// 已关闭的TracerProvider不再采集，恢复原来的全局设置，除非期间已被替换
+		if otel.GetTracerProvider() == tp {
+			otel.SetTracerProvider(prevTP)
+			otel.SetTextMapPropagator(prevProp)
+		}
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := tp.ForceFlush(ctx); err != nil {
+			logger.Errorf("链路追踪导出失败 %v", err)
+		}
+		if err := tp.Shutdown(ctx); err != nil {
+			logger.Errorf("链路追踪关闭失败 %v", err)
+		}
+	}
+	return tp, f, nil
+}
@@ -0,0 +1,74 @@
+package vbasedata
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func TestNewTracerProvider(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(prev)
+
+	if _, _, err := NewTracerProvider(&App{Trace: "jaeger"}, newTestLogger()); err == nil {
+		t.Fatal("unknown mode should fail")
+	}
+	tp, shutdown, err := NewTracerProvider(&App{Trace: TraceNone}, newTestLogger())
+	if err != nil || tp != nil {
+		t.Fatalf("none mode = %v, %v", tp, err)
+	}
+	shutdown()
+
+	for _, mode := range []string{TraceStdout, "otlp-http://127.0.0.1:4318/v1/traces"} {
+		_, shutdown, err := NewTracerProvider(&App{AppName: "demo", Trace: mode}, newTestLogger())
+		if err != nil {
+			t.Fatalf("%v: %v", mode, err)
+		}
+		shutdown()
+	}
+
+	MemoryTraceExporter.Reset()
+	_, shutdown, err = NewTracerProvider(&App{AppName: "demo", Env: "test", Trace: TraceMemory}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := otel.Tracer("test").Start(context.Background(), "op")
+	span.End()
+
+	spans := MemoryTraceExporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "op" {
+		t.Fatalf("spans = %v", spans)
+	}
+	attrs := spans[0].Resource.Attributes()
+	var service, env bool
+	for _, kv := range attrs {
+		if kv == semconv.ServiceName("demo") {
+			service = true
+		}
+		if kv == semconv.DeploymentEnvironment("test") {
+			env = true
+		}
+	}
+	if !service || !env {
+		t.Fatalf("resource = %v", attrs)
+	}
+
+	shutdown()
+	if otel.GetTracerProvider() != prev {
+		t.Fatal("previous global provider should be restored after shutdown")
+	}
+
+	// 初始化用的ctx取消后仍能正常关闭
+	ctx, cancel := context.WithCancel(context.Background())
+	tp, shutdown, err = NewTracerProviderContext(ctx, &App{Trace: TraceStdout}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	shutdown()
+	if otel.GetTracerProvider() == tp {
+		t.Fatal("shut down provider still installed")
+	}
+}
@@ -0,0 +1,96 @@
+package vbasedata
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TxOptions 事务配置
+type TxOptions struct {
+	MaxRetries     int                // 死锁/序列化失败时的最大重试次数，默认3，小于0不重试
+	InitialBackoff time.Duration      // 首次重试等待时间，默认20ms，之后指数增长
+	MaxBackoff     time.Duration      // 最大重试等待时间，默认1s
+	Isolation      sql.IsolationLevel // 隔离级别，默认使用数据库配置
+	ReadOnly       bool               // 只读事务
+}
+
+// txCtxKey 按数据库区分ctx中的事务，同一次gorm.Open派生的db共用一个*gorm.Config
+type txCtxKey struct {
+	config *gorm.Config
+}
+
+// WithTx 在事务中执行fn，事务通过ctx传递给fn，遇到死锁或序列化失败时整体重试。
+// ctx中已有同一数据库的事务时不再开启新事务，而是使用savepoint嵌套，且不重试，由最外层事务负责重试；其他数据库的事务不受影响。
+func WithTx(ctx context.Context, db *gorm.DB, opts *TxOptions, fn func(ctx context.Context, tx *gorm.DB) error) error {
+	key := txCtxKey{db.Config}
+	run := func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, key, tx), tx)
+	}
+
+	if outer, ok := ctx.Value(key).(*gorm.DB); ok {
+		return outer.Transaction(run)
+	}
+
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+	maxRetries := opts.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultTxMaxRetries
+	}
+	backoff := opts.InitialBackoff
+	if backoff == 0 {
+		backoff = DefaultTxInitialBackoff
+	}
+	maxBackoff := opts.MaxBackoff
+	if maxBackoff == 0 {
+		maxBackoff = DefaultTxMaxBackoff
+	}
+	var sqlOpts *sql.TxOptions
+	if opts.Isolation != sql.LevelDefault || opts.ReadOnly {
+		sqlOpts = &sql.TxOptions{
+			Isolation: opts.Isolation,
+			ReadOnly:  opts.ReadOnly,
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		var err error
+		if sqlOpts != nil {
+			err = db.WithContext(ctx).Transaction(run, sqlOpts)
+		} else {
+			err = db.WithContext(ctx).Transaction(run)
+		}
+		if err == nil || attempt >= maxRetries || !isRetryableTxErr(err) {
+			return err
+		}
+
+		// 加入随机抖动，避免冲突的事务同时重试
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// TxFromContext 返回ctx中db所属数据库的事务，不在事务中时返回绑定ctx的db，供仓储层加入外层事务
+func TxFromContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txCtxKey{db.Config}).(*gorm.DB); ok {
+		return tx
+	}
+	return db.WithContext(ctx)
+}
+
+func isRetryableTxErr(err error) bool {
+	return isMySQLRetryableTxErr(err) || isPGRetryableTxErr(err)
+}
@@ -0,0 +1,120 @@
+package vbasedata
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestWithTx(t *testing.T) {
+	db, closeFn, err := NewGorm(&GormConfig{Name: "tx", DBPath: filepath.Join(t.TempDir(), "tx.db")}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFn()
+	if err := db.Exec("CREATE TABLE item (id INTEGER PRIMARY KEY, name TEXT)").Error; err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// 死锁错误重试
+	attempts := 0
+	err = WithTx(ctx, db, &TxOptions{InitialBackoff: time.Millisecond}, func(ctx context.Context, tx *gorm.DB) error {
+		attempts++
+		if attempts < 3 {
+			return &mysqldriver.MySQLError{Number: 1213, Message: "Deadlock found"}
+		}
+		return TxFromContext(ctx, db).Exec("INSERT INTO item (id, name) VALUES (1, 'a')").Error
+	})
+	if err != nil || attempts != 3 {
+		t.Fatalf("attempts = %d, err = %v", attempts, err)
+	}
+
+	// 普通错误不重试
+	attempts = 0
+	err = WithTx(ctx, db, nil, func(ctx context.Context, tx *gorm.DB) error {
+		attempts++
+		return errors.New("boom")
+	})
+	if err == nil || attempts != 1 {
+		t.Fatalf("attempts = %d, err = %v", attempts, err)
+	}
+
+	// 嵌套事务回滚到savepoint，外层提交
+	err = WithTx(ctx, db, nil, func(ctx context.Context, tx *gorm.DB) error {
+		if err := tx.Exec("INSERT INTO item (id, name) VALUES (2, 'b')").Error; err != nil {
+			return err
+		}
+		_ = WithTx(ctx, db, nil, func(ctx context.Context, tx *gorm.DB) error {
+			if err := TxFromContext(ctx, db).Exec("INSERT INTO item (id, name) VALUES (3, 'c')").Error; err != nil {
+				return err
+			}
+			return errors.New("rollback inner")
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	db.Table("item").Count(&n)
+	if n != 2 {
+		t.Fatalf("count = %d, want 2", n)
+	}
+}
+
+func TestWithTx_TwoDatabases(t *testing.T) {
+	dir := t.TempDir()
+	db1, close1, err := NewGorm(&GormConfig{Name: "tx1", DBPath: filepath.Join(dir, "tx1.db")}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close1()
+	db2, close2, err := NewGorm(&GormConfig{Name: "tx2", DBPath: filepath.Join(dir, "tx2.db")}, newTestLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close2()
+	if err := db1.Exec("CREATE TABLE t1 (id INTEGER PRIMARY KEY)").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db2.Exec("CREATE TABLE t2 (id INTEGER PRIMARY KEY)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	errInner := errors.New("rollback db2")
+	err = WithTx(ctx, db1, nil, func(ctx context.Context, tx *gorm.DB) error {
+		if err := TxFromContext(ctx, db1).Exec("INSERT INTO t1 (id) VALUES (1)").Error; err != nil {
+			return err
+		}
+		// 另一个数据库开启自己的事务，不加入db1的事务
+		err := WithTx(ctx, db2, nil, func(ctx context.Context, tx2 *gorm.DB) error {
+			if TxFromContext(ctx, db1) != tx {
+				t.Error("db1 transaction should still be visible")
+			}
+			if err := TxFromContext(ctx, db2).Exec("INSERT INTO t2 (id) VALUES (1)").Error; err != nil {
+				return err
+			}
+			return errInner
+		})
+		if !errors.Is(err, errInner) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n1, n2 int64
+	db1.Raw("SELECT COUNT(*) FROM t1").Scan(&n1)
+	db2.Raw("SELECT COUNT(*) FROM t2").Scan(&n2)
+	if n1 != 1 || n2 != 0 {
+		t.Fatalf("t1 = %d, t2 = %d", n1, n2)
+	}
+}